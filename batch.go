@@ -0,0 +1,88 @@
+package cache
+
+import "time"
+
+// GetMany 读取多个key，每个分片只加一次锁，返回的map中不包含不存在、已过期及缓存的加载错误的key
+func (c *Cache) GetMany(keys []string) map[string]interface{} {
+	list, found := c.GetManySlice(keys)
+	values := make(map[string]interface{}, len(keys))
+	for i, ok := range found {
+		if ok {
+			values[keys[i]] = list[i]
+		}
+	}
+	return values
+}
+
+// GetManySlice 与GetMany相同，按keys的顺序返回value，found表示对应的key是否存在
+func (c *Cache) GetManySlice(keys []string) (values []interface{}, found []bool) {
+	values = make([]interface{}, len(keys))
+	found = make([]bool, len(keys))
+	c.group(keys, func(index uint32, indexes []int) {
+		c.s.GetMany(index, keys, indexes, values, found)
+	})
+	for i, ok := range found {
+		if !ok {
+			continue
+		}
+		if _, negative := negativeErr(values[i]); negative {
+			values[i] = nil
+			found[i] = false
+		}
+	}
+	return values, found
+}
+
+// SetMany 按ttl写入多个key，每个分片只加一次锁，ttl < 0 表示不过期
+func (c *Cache) SetMany(items map[string]interface{}, ttl time.Duration) {
+	keys := make([]string, 0, len(items))
+	setItems := make([]setItem, 0, len(items))
+	for key, value := range items {
+		expAt, meta := c.expiry(ttl)
+		keys = append(keys, key)
+		setItems = append(setItems, setItem{key: key, value: value, expAt: expAt, meta: meta})
+	}
+	c.group(keys, func(index uint32, indexes []int) {
+		c.s.SetMany(index, setItems, indexes)
+	})
+}
+
+// DelMany 删除多个key，每个分片只加一次锁
+func (c *Cache) DelMany(keys []string) {
+	c.group(keys, func(index uint32, indexes []int) {
+		c.s.DelMany(index, keys, indexes)
+	})
+}
+
+// group 按分片对keys计数排序，依次对每个分片调用handle，indexes为该分片的key在keys中的下标
+func (c *Cache) group(keys []string, handle func(index uint32, indexes []int)) {
+	shards := make([]uint32, len(keys))
+	var max uint32
+	for i, key := range keys {
+		shards[i] = c.s.Index(key)
+		if shards[i] > max {
+			max = shards[i]
+		}
+	}
+
+	// offsets[index]为分片index的起始位置，pos为写入位置
+	buf := make([]int, len(keys)+2*int(max)+3)
+	sorted, offsets, pos := buf[:len(keys)], buf[len(keys):len(keys)+int(max)+2], buf[len(keys)+int(max)+2:]
+	for _, index := range shards {
+		offsets[index+1]++
+	}
+	for i := 1; i < len(offsets); i++ {
+		offsets[i] += offsets[i-1]
+	}
+	copy(pos, offsets)
+	for i, index := range shards {
+		sorted[pos[index]] = i
+		pos[index]++
+	}
+
+	for index := uint32(0); index <= max; index++ {
+		if start, end := offsets[index], offsets[index+1]; start < end {
+			handle(index, sorted[start:end])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache_GetMany(t *testing.T) {
+	cache := NewCache(4, 10, WithNegativeCache(time.Hour, nil))
+	cache.SetMany(map[string]interface{}{"t1": 1, "t2": 2, "t3": 3}, time.Hour)
+	cache.SetEx("t4", 4, time.Millisecond)
+	cache.storeErr(cache.s.Index("t5"), "t5", ErrNil)
+	time.Sleep(2 * time.Millisecond)
+
+	keys := []string{"t1", "t2", "t3", "t4", "t5", "t6"}
+	values := cache.GetMany(keys)
+	if len(values) != 3 || values["t1"] != 1 || values["t2"] != 2 || values["t3"] != 3 {
+		t.Fatal("get many: ", values)
+	}
+
+	list, found := cache.GetManySlice(keys)
+	for i, key := range keys {
+		if found[i] != (i < 3) {
+			t.Fatalf("%s found should be %v", key, i < 3)
+		}
+		if found[i] && list[i] != i+1 {
+			t.Fatalf("%s should be %d, got %v", key, i+1, list[i])
+		}
+	}
+
+	// 过期的key被删除
+	if _, _, ok := cache.s.Peek(cache.s.Index("t4"), "t4"); ok {
+		t.Fatal("expired t4 should be deleted")
+	}
+	// 缓存的加载错误与Get相同计为命中
+	stats := cache.Stats()
+	if stats.Hits != 8 || stats.Misses != 4 || stats.Expirations != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
+
+func TestCache_DelMany(t *testing.T) {
+	cache := NewCacheWithGC(4, 10, time.Minute)
+	defer cache.Close()
+	timer := cache.s.(*cacheTimer).expirer.(*wheelExpirer)
+
+	items := make(map[string]interface{})
+	keys := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		key := "t" + strconv.Itoa(i)
+		items[key] = i
+		keys = append(keys, key)
+	}
+	cache.SetMany(items, time.Hour)
+	if n := timer.Len(); n != 20 {
+		t.Fatal("timer should have 20 nodes, got ", n)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl <= 59*time.Minute {
+		t.Fatal("t1 ttl should be 1h, got ", ttl)
+	}
+
+	cache.DelMany(keys[:10])
+	if values := cache.GetMany(keys); len(values) != 10 {
+		t.Fatal("should have 10 keys, got ", len(values))
+	}
+	if n := timer.Len(); n != 10 {
+		t.Fatal("timer should have 10 nodes, got ", n)
+	}
+}
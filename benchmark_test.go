@@ -112,3 +112,173 @@ func readFromCacheWithGC(b *testing.B, num int) {
 		})
 	})
 }
+
+const (
+	_traceKeys     = 1 << 14 // 热点key空间
+	_traceLen      = 1 << 17
+	_traceCapacity = 1 << 10
+)
+
+var _policies = []struct {
+	name    string
+	factory PolicyFactory
+}{
+	{"lru", NewLRUPolicy},
+	{"lfu", NewLFUPolicy},
+	{"fifo", NewFIFOPolicy},
+	{"random", NewRandomPolicy},
+	{"s3fifo", NewS3FIFOPolicy},
+	{"tinylfu", NewTinyLFUPolicy},
+}
+
+// zipfTrace 服从Zipf分布的访问序列
+func zipfTrace(n int, seed int64) []string {
+	z := rand.NewZipf(rand.New(rand.NewSource(seed)), 1.01, 1, _traceKeys-1)
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = strconv.FormatUint(z.Uint64(), 10)
+	}
+	return trace
+}
+
+// scanTrace Zipf访问中穿插只访问一次的顺序扫描
+func scanTrace(n int, seed int64) []string {
+	hot := zipfTrace(n, seed)
+	trace := make([]string, 0, n)
+	var scan int
+	for i := 0; len(trace) < n; i++ {
+		trace = append(trace, hot[i])
+		if i%1000 == 999 {
+			for j := 0; j < 2*_traceCapacity && len(trace) < n; j++ {
+				trace = append(trace, "scan-"+strconv.Itoa(scan))
+				scan++
+			}
+		}
+	}
+	return trace
+}
+
+// hitRatio 按访问序列读取缓存，未命中时写入
+func hitRatio(cache *Cache, trace []string, n int) float64 {
+	var hits int
+	for i := 0; i < n; i++ {
+		key := trace[i%len(trace)]
+		if _, err := cache.Get(key); err == nil {
+			hits++
+		} else {
+			cache.Set(key, i)
+		}
+	}
+	return float64(hits) / float64(n)
+}
+
+func BenchmarkEvictionPolicy_HitRatio(b *testing.B) {
+	traces := []struct {
+		name  string
+		trace []string
+	}{
+		{"zipf", zipfTrace(_traceLen, 1)},
+		{"scan", scanTrace(_traceLen, 1)},
+	}
+	for _, tr := range traces {
+		for _, p := range _policies {
+			b.Run(fmt.Sprintf("%s-%s", tr.name, p.name), func(b *testing.B) {
+				newCache := func() *Cache {
+					return NewCache(1, _traceCapacity, WithMaxEntries(_traceCapacity), WithEvictionPolicy(p.factory))
+				}
+				// 命中率按完整的访问序列计算一次，b.N只用于计时
+				ratio := hitRatio(newCache(), tr.trace, len(tr.trace))
+				cache := newCache()
+				b.ReportAllocs()
+				b.ResetTimer()
+				hitRatio(cache, tr.trace, b.N)
+				b.StopTimer()
+				b.ReportMetric(ratio, "hit-ratio")
+			})
+		}
+	}
+}
+
+func BenchmarkClock_Now(b *testing.B) {
+	coarse := NewCoarseClock(time.Millisecond)
+	defer coarse.Stop()
+	for _, c := range []struct {
+		name  string
+		clock Clock
+	}{
+		{"precise", _realClock},
+		{"coarse", coarse},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = c.clock.Now().UnixNano()
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadFromCache_Clock(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		opts []Option
+	}{
+		{"precise", nil},
+		{"coarse", []Option{WithCoarseClock(time.Millisecond)}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			cache := NewCache(32, 10000, c.opts...)
+			defer cache.Close()
+			keys := make([]string, 10000)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+				cache.SetEx(keys[i], message, time.Hour)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					_, _ = cache.Get(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkReadFromCache_GetMany(b *testing.B) {
+	cache := NewCache(32, 10000)
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		cache.Set(keys[i], i)
+	}
+	batch := 100
+
+	b.Run("Get", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				start := r.Intn(len(keys) - batch)
+				for _, key := range keys[start : start+batch] {
+					_, _ = cache.Get(key)
+				}
+			}
+		})
+	})
+	b.Run("GetMany", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				start := r.Intn(len(keys) - batch)
+				_ = cache.GetMany(keys[start : start+batch])
+			}
+		})
+	})
+}
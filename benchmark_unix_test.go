@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd
+
+package cache
+
+import (
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// cpuTime 进程的用户态及内核态cpu时间
+func cpuTime(b *testing.B) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		b.Fatal(err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// BenchmarkExpiryStrategy_Idle 缓存空闲时清理过期key的cpu占用，cpu-us/op为每10ms的cpu时间
+func BenchmarkExpiryStrategy_Idle(b *testing.B) {
+	for _, st := range _strategies {
+		b.Run(st.name, func(b *testing.B) {
+			cache := NewCacheWithGC(16, 1000, time.Millisecond, WithExpiryStrategy(st.strategy))
+			defer cache.Close()
+			for i := 0; i < 10000; i++ {
+				cache.SetEx(strconv.Itoa(i), i, time.Hour+time.Duration(i)*time.Second)
+			}
+
+			b.ResetTimer()
+			start := cpuTime(b)
+			for i := 0; i < b.N; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			b.ReportMetric(float64(cpuTime(b)-start)/float64(time.Microsecond)/float64(b.N), "cpu-us/op")
+		})
+	}
+}
@@ -3,31 +3,52 @@ package cache
 import (
 	"bufio"
 	"compress/zlib"
+	"context"
 	"io"
 	"time"
 )
 
 type Cache struct {
-	s sharedSet
+	s             sharedSet
+	loadTimeout   time.Duration
+	negativeTTL   time.Duration
+	negativeMatch func(err error) bool
+	onRefreshErr  func(key string, err error)
+	jitter        *jitter
+	clock         Clock
 }
 
-func NewCache(sharedNum, sharedCap int) *Cache {
-	return &Cache{
-		s: newCache(sharedNum, sharedCap),
-	}
+func NewCache(sharedNum, sharedCap int, opts ...Option) *Cache {
+	o := newOptions(opts)
+	return newCacheWithOptions(newCache(sharedNum, sharedCap, o), o)
+}
+
+func NewCacheWithGC(sharedNum, sharedCap int, gcInterval time.Duration, opts ...Option) *Cache {
+	o := newOptions(opts)
+	return newCacheWithOptions(newCacheTimer(sharedNum, sharedCap, gcInterval, o), o)
 }
 
-func NewCacheWithGC(sharedNum, sharedCap int, gcInterval time.Duration) *Cache {
+func newCacheWithOptions(s sharedSet, o *options) *Cache {
 	return &Cache{
-		s: newCacheTimer(sharedNum, sharedCap, gcInterval),
+		s:             s,
+		loadTimeout:   o.loadTimeout,
+		negativeTTL:   o.negativeTTL,
+		negativeMatch: o.negativeMatch,
+		onRefreshErr:  o.onRefreshErr,
+		jitter:        newJitter(o.jitterRatio, o.jitterMax, o.jitterSeed, o.jitterSeeded),
+		clock:         o.clock,
 	}
 }
 
+// Get key为缓存的加载错误时返回该错误
 func (c *Cache) Get(key string) (interface{}, error) {
 	value, ok := c.s.Get(c.s.Index(key), key)
 	if !ok {
 		return nil, ErrNil
 	}
+	if err, ok := negativeErr(value); ok {
+		return nil, err
+	}
 	return value, nil
 }
 
@@ -40,28 +61,194 @@ func (c *Cache) SetEx(key string, value interface{}, ttl time.Duration) {
 		c.Set(key, value)
 		return
 	}
-	expAt := time.Now().UnixNano() + int64(ttl)
-	c.s.SetEx(c.s.Index(key), key, value, expAt)
+	ttl = c.jitter.Apply(ttl)
+	c.s.SetWithMeta(c.s.Index(key), key, value, c.clock.Now().UnixNano()+int64(ttl), entryMeta{ttl: int64(ttl)})
+}
+
+// SetWithCost 以指定成本写入，用于WithMaxCost的容量限制
+func (c *Cache) SetWithCost(key string, value interface{}, cost int64) {
+	c.s.SetWithCost(c.s.Index(key), key, value, -1, cost)
+}
+
+func (c *Cache) SetExWithCost(key string, value interface{}, ttl time.Duration, cost int64) {
+	if ttl < 0 {
+		c.SetWithCost(key, value, cost)
+		return
+	}
+	expAt := c.clock.Now().UnixNano() + int64(c.jitter.Apply(ttl))
+	c.s.SetWithCost(c.s.Index(key), key, value, expAt, cost)
+}
+
+// SetIdle 写入滑动过期的key，idle时间内没有被Get或Load读取到时过期，每次读取到时重新计时
+func (c *Cache) SetIdle(key string, value interface{}, idle time.Duration) {
+	c.SetIdleWithLimit(key, value, idle, -1)
+}
+
+// SetIdleWithLimit 写入滑动过期的key，同时限制自写入起最多存活limit，limit < 0 表示不限制
+// idle <= 0 时按limit写入固定过期时间的key
+func (c *Cache) SetIdleWithLimit(key string, value interface{}, idle, limit time.Duration) {
+	if idle <= 0 {
+		c.SetEx(key, value, limit)
+		return
+	}
+	now := c.clock.Now().UnixNano()
+	meta := entryMeta{idle: int64(idle)}
+	expAt := now + int64(idle)
+	if limit >= 0 {
+		meta.deadline = now + int64(limit)
+		if meta.deadline < expAt {
+			expAt = meta.deadline
+		}
+	}
+	c.s.SetWithMeta(c.s.Index(key), key, value, expAt, meta)
 }
 
 func (c *Cache) Del(key string) {
 	c.s.Del(c.s.Index(key), key)
 }
 
-func (c *Cache) LoadWithEx(key string, fn LoadFunc, ttl time.Duration) (interface{}, error) {
-	return c.load(key, fn, ttl)
+// TTL key的剩余过期时间，不过期时返回NoExpiration，不存在或已过期时返回ErrNil，key为缓存的加载错误时返回该错误
+func (c *Cache) TTL(key string) (time.Duration, error) {
+	value, expAt, ok := c.s.Peek(c.s.Index(key), key)
+	if !ok {
+		return 0, ErrNil
+	}
+	now := c.clock.Now().UnixNano()
+	if expAt >= 0 && expAt <= now {
+		return 0, ErrNil
+	}
+	if err, ok := negativeErr(value); ok {
+		return 0, err
+	}
+	if expAt < 0 {
+		return NoExpiration, nil
+	}
+	return time.Duration(expAt - now), nil
+}
+
+// Expire 修改key的过期时间而不改写value，ttl < 0 表示不过期，Touch按该ttl续期，key不存在或已过期时返回false
+func (c *Cache) Expire(key string, ttl time.Duration) bool {
+	if ttl < 0 {
+		return c.Persist(key)
+	}
+	ttl = c.jitter.Apply(ttl)
+	return c.s.Expire(c.s.Index(key), key, c.clock.Now().UnixNano()+int64(ttl), int64(ttl))
+}
+
+// ExpireAt 修改key在t时过期，t不晚于当前时间（包括零值时间）时立即删除key，key不存在或已过期时返回false
+func (c *Cache) ExpireAt(key string, t time.Time) bool {
+	if t.After(c.clock.Now()) {
+		return c.s.Expire(c.s.Index(key), key, t.UnixNano(), 0)
+	}
+	action, _ := c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if item == nil {
+			return nil, 0, entryMeta{}, computeNone
+		}
+		return nil, 0, entryMeta{}, computeDelete
+	})
+	return action == computeDelete
+}
+
+// Persist 删除key的过期时间，key不存在或已过期时返回false
+func (c *Cache) Persist(key string) bool {
+	return c.s.Expire(c.s.Index(key), key, -1, 0)
+}
+
+// Touch 按写入时的ttl续期，滑动过期的key重新计时，只对SetEx、SetIdle、Expire及加载写入的带过期时间的key生效，其它情况返回false
+func (c *Cache) Touch(key string) bool {
+	_, ok := c.s.Touch(c.s.Index(key), key)
+	return ok
+}
+
+func (c *Cache) LoadWithEx(key string, fn LoadFunc, ttl time.Duration, opts ...LoadOption) (interface{}, error) {
+	return c.load(key, fn, ttl, newLoadOptions(opts))
 }
 
+// LoadAsyncWithEx 过期的value直接返回并异步加载，过期的key不会被expirer及读取删除，只通过淘汰或Del删除
 func (c *Cache) LoadAsyncWithEx(key string, fn LoadFunc, ttl time.Duration) (interface{}, error) {
-	return c.loadAsync(key, fn, ttl)
+	return c.loadAsync(key, fn, ttl, -1)
+}
+
+// LoadAsyncWithStale 过期不超过maxStale的value直接返回并异步加载，超过maxStale时同步加载
+// 加载的key过期后继续保留maxStale，之后才会被expirer删除
+func (c *Cache) LoadAsyncWithStale(key string, fn LoadFunc, ttl, maxStale time.Duration) (interface{}, error) {
+	if maxStale < 0 {
+		maxStale = 0
+	}
+	return c.loadAsync(key, fn, ttl, maxStale)
 }
 
 func (c *Cache) Load(key string, fn LoadFunc) (interface{}, error) {
-	return c.load(key, fn, -1)
+	return c.load(key, fn, -1, loadOptions{})
 }
 
+// LoadCtx 不存在时调用fn加载，ttl < 0 表示不过期
+// 同一key的并发调用共享一次加载，每个调用方在自己的ctx结束时返回ctx.Err()，加载继续执行并在成功后写入缓存
+func (c *Cache) LoadCtx(ctx context.Context, key string, fn LoadCtxFunc, ttl time.Duration) (interface{}, error) {
+	i := c.s.Index(key)
+	value, ok := c.s.Get(i, key)
+	if ok {
+		if err, ok := negativeErr(value); ok {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	value, err, _ := c.s.LoadCtx(ctx, i, key, func() (interface{}, error) {
+		loadCtx, cancel := c.loadContext(ctx)
+		defer cancel()
+
+		start := time.Now()
+		v, err := fn(loadCtx)
+		if err != nil {
+			c.storeErr(i, key, err)
+			return nil, err
+		}
+		c.store(i, key, v, ttl, 0, time.Since(start))
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Scan 跳过缓存的加载错误
 func (c *Cache) Scan(handle func(key string, value interface{}, expAt int64)) {
-	c.s.Scan(handle)
+	c.s.Scan(func(key string, value interface{}, expAt int64) {
+		if _, ok := negativeErr(value); ok {
+			return
+		}
+		handle(key, value, expAt)
+	})
+}
+
+// Close 释放缓存占用的后台资源，可重复及并发调用
+// 关闭后缓存仍可读写，但带过期时间的key只在读取时惰性删除
+func (c *Cache) Close() {
+	c.s.Close()
+}
+
+func (c *Cache) Closed() bool {
+	return c.s.Closed()
+}
+
+// Stats 所有分片的统计之和
+func (c *Cache) Stats() Stats {
+	var stats Stats
+	for _, s := range c.s.SharedStats() {
+		stats.add(s)
+	}
+	return stats
+}
+
+// SharedStats 每个分片的统计，下标为分片序号
+func (c *Cache) SharedStats() []Stats {
+	return c.s.SharedStats()
+}
+
+func (c *Cache) ResetStats() {
+	c.s.ResetStats()
 }
 
 func (c *Cache) SaveBaseType(w io.Writer) {
@@ -71,7 +258,7 @@ func (c *Cache) SaveBaseType(w io.Writer) {
 	zw, _ := zlib.NewWriterLevel(bw, zlib.BestSpeed)
 	defer zw.Close()
 
-	now := time.Now().UnixNano()
+	now := c.clock.Now().UnixNano()
 	c.s.Scan(func(key string, value interface{}, expAt int64) {
 		if expAt > now || expAt < 0 {
 			kv := &kvItem{}
@@ -91,7 +278,7 @@ func (c *Cache) LoadBaseType(r io.Reader) error {
 	}
 	defer zr.Close()
 
-	now := time.Now().UnixNano()
+	now := c.clock.Now().UnixNano()
 	for {
 		kv := &kvItem{}
 		if !kv.InitMetaFromReader(zr) {
@@ -111,6 +298,7 @@ func (c *Cache) LoadBaseType(r io.Reader) error {
 				if expAt < 0 {
 					c.s.Set(c.s.Index(key), key, value)
 				} else {
+					expAt = now + int64(c.jitter.Apply(time.Duration(expAt-now)))
 					c.s.SetEx(c.s.Index(key), key, value, expAt)
 				}
 			}
@@ -118,67 +306,99 @@ func (c *Cache) LoadBaseType(r io.Reader) error {
 	}
 }
 
-func (c *Cache) load(key string, fn LoadFunc, ttl time.Duration) (interface{}, error) {
+func (c *Cache) load(key string, fn LoadFunc, ttl time.Duration, lo loadOptions) (interface{}, error) {
 	i := c.s.Index(key)
-	value, ok := c.s.Get(i, key)
+	value, expAt, meta, ok := c.s.GetMeta(i, key)
 	if ok {
-		return value, nil
-	}
-	var (
-		err        error
-		concurrent bool
-	)
-	value, err, concurrent = c.s.Load(i, key, fn)
-	if err != nil {
-		return nil, err
-	}
-	if !concurrent {
-		if ttl < 0 {
-			c.s.Set(i, key, value)
-		} else {
-			c.s.SetEx(i, key, value, time.Now().UnixNano()+int64(ttl))
+		if err, ok := negativeErr(value); ok {
+			return nil, err
 		}
+		now := c.clock.Now().UnixNano()
+		if lo.shouldRefresh(now, expAt, meta) {
+			c.refresh(i, key, fn, ttl, 0)
+			return value, nil
+		}
+		if !lo.shouldRecompute(now, expAt, meta) {
+			return value, nil
+		}
+		// XFetch提前同步加载
 	}
-	return value, nil
+	return c.loadSync(i, key, fn, ttl, 0)
 }
 
-func (c *Cache) loadAsync(key string, fn LoadFunc, ttl time.Duration) (interface{}, error) {
+// loadAsync maxStale < 0 表示过期的value一直可用
+func (c *Cache) loadAsync(key string, fn LoadFunc, ttl, maxStale time.Duration) (interface{}, error) {
 	i := c.s.Index(key)
 	value, expAt, ok := c.s.GetIgnoreExp(i, key)
 	if ok {
-		if expAt >= 0 && expAt < time.Now().UnixNano() { // 过期异步加载
-			go func(k string, e time.Duration, i uint32, f func() (interface{}, error)) {
-				v, err, concurrent := c.s.Load(i, k, f)
-				if err != nil {
-					return
-				}
-				if concurrent {
-					return
-				}
-				if e < 0 {
-					c.s.Set(i, k, v)
-				} else {
-					c.s.SetEx(i, k, v, time.Now().UnixNano()+int64(e))
-				}
-			}(key, ttl, i, fn)
+		now := c.clock.Now().UnixNano()
+		expired := expAt >= 0 && expAt < now
+		if err, negative := negativeErr(value); negative {
+			if !expired {
+				return nil, err
+			}
+		} else if !expired {
+			return value, nil
+		} else if maxStale < 0 || now-expAt <= int64(maxStale) { // 过期异步加载
+			c.refresh(i, key, fn, ttl, maxStale)
+			return value, nil
 		}
-		return value, nil
 	}
-	var (
-		err        error
-		concurrent bool
-	)
-	// 不存在同步加载
-	value, err, concurrent = c.s.Load(i, key, fn)
+	// 不存在、超过maxStale或缓存的加载错误已过期时同步加载
+	return c.loadSync(i, key, fn, ttl, maxStale)
+}
+
+// loadSync 同一key的并发调用共享一次加载，由实际执行加载的调用写入缓存
+func (c *Cache) loadSync(i uint32, key string, fn LoadFunc, ttl, stale time.Duration) (interface{}, error) {
+	start := time.Now()
+	value, err, concurrent := c.s.Load(i, key, fn)
+	if concurrent {
+		return value, err
+	}
 	if err != nil {
+		c.storeErr(i, key, err)
 		return nil, err
 	}
-	if !concurrent {
-		if ttl < 0 {
-			c.s.Set(i, key, value)
-		} else {
-			c.s.SetEx(i, key, value, time.Now().UnixNano()+int64(ttl))
+	c.store(i, key, value, ttl, stale, time.Since(start))
+	return value, nil
+}
+
+// store 写入加载的value并记录加载耗时，ttl < 0 表示不过期，stale为过期后继续保留的时间，< 0 表示一直保留
+func (c *Cache) store(i uint32, key string, value interface{}, ttl, stale, delta time.Duration) {
+	if ttl < 0 {
+		c.s.Set(i, key, value)
+	} else {
+		ttl = c.jitter.Apply(ttl)
+		c.s.SetWithMeta(i, key, value, c.clock.Now().UnixNano()+int64(ttl), entryMeta{ttl: int64(ttl), delta: int64(delta), stale: int64(stale)})
+	}
+}
+
+// refresh key没有正在执行的加载时异步加载，失败时只通过onRefreshErr报告，不缓存加载错误
+func (c *Cache) refresh(i uint32, key string, fn LoadFunc, ttl, stale time.Duration) {
+	var delta time.Duration
+	timed := func() (interface{}, error) {
+		start := time.Now()
+		defer func() {
+			delta = time.Since(start)
+		}()
+		return fn()
+	}
+	c.s.Refresh(i, key, timed, func(v interface{}, err error) {
+		if err != nil { // 保留仍可使用的旧value
+			if c.onRefreshErr != nil {
+				c.onRefreshErr(key, err)
+			}
+			return
 		}
+		c.store(i, key, v, ttl, stale, delta)
+	})
+}
+
+// loadContext 加载函数使用的ctx，保留调用方ctx中的value，但不随调用方取消
+func (c *Cache) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	loadCtx := context.Context(detachedContext{ctx})
+	if c.loadTimeout > 0 {
+		return context.WithTimeout(loadCtx, c.loadTimeout)
 	}
-	return value, nil
+	return context.WithCancel(loadCtx)
 }
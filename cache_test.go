@@ -1,14 +1,21 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func TestNewCache(t *testing.T) {
 	cache := NewCacheWithGC(2, 50, time.Second)
+	defer cache.Close()
 	cache.Set("t1", 123)
 	val, err := cache.Get("t1")
 	if err != nil {
@@ -21,6 +28,7 @@ func TestNewCache(t *testing.T) {
 
 func TestSetEx(t *testing.T) {
 	cache := NewCacheWithGC(2, 50, time.Millisecond)
+	defer cache.Close()
 	cache.SetEx("test", 123, 5*time.Millisecond)
 	time.Sleep(time.Millisecond)
 	fmt.Println(cache.Get("test"))
@@ -28,6 +36,300 @@ func TestSetEx(t *testing.T) {
 	fmt.Println(cache.Get("test"))
 }
 
+func TestCache_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	cache := NewCacheWithGC(2, 50, time.Millisecond)
+	cache.SetEx("t1", 1, time.Hour)
+	cache.SetEx("t2", 2, 5*time.Millisecond)
+
+	var w sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			cache.Close()
+		}()
+	}
+	w.Wait()
+
+	if !cache.Closed() {
+		t.Fatal("cache should be closed")
+	}
+
+	var after int
+	for i := 0; i < 100; i++ {
+		after = runtime.NumGoroutine()
+		if after <= before {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if after > before {
+		t.Fatalf("goroutine leaked, before: %d, after: %d", before, after)
+	}
+
+	// 关闭后过期key惰性删除
+	cache.SetEx("t3", 3, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if _, err := cache.Get("t3"); !ErrIsNotFound(err) {
+		t.Fatal("t3 should be expired")
+	}
+	if val, err := cache.Get("t1"); err != nil || val.(int) != 1 {
+		t.Fatal("t1 should exists")
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	cache := NewCache(4, 10, WithMaxEntries(100))
+	var w sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		w.Add(1)
+		go func(n int) {
+			defer w.Done()
+			for j := 0; j < 1000; j++ {
+				key := strconv.Itoa(n*1000 + j)
+				cache.Set(key, j)
+				_, _ = cache.Get(key)
+			}
+		}(i)
+	}
+	w.Wait()
+
+	var num int
+	cache.Scan(func(key string, value interface{}, expAt int64) {
+		num++
+	})
+	if num > 100 {
+		t.Fatal("cache entries should be limited to 100, got ", num)
+	}
+}
+
+func TestCache_MaxEntriesNotDivisible(t *testing.T) {
+	cases := []struct {
+		sharedNum, maxEntries, limit int
+	}{
+		{4, 10, 8},
+		{16, 10, 16}, // 每个分片至少1个key
+		{1, 10, 10},
+	}
+	for _, c := range cases {
+		cache := NewCache(c.sharedNum, 0, WithMaxEntries(c.maxEntries))
+		for i := 0; i < 1000; i++ {
+			cache.Set(strconv.Itoa(i), i)
+		}
+		var num int
+		cache.Scan(func(key string, value interface{}, expAt int64) {
+			num++
+		})
+		if num != c.limit {
+			t.Fatalf("%d shards with max %d should hold %d keys, got %d", c.sharedNum, c.maxEntries, c.limit, num)
+		}
+	}
+}
+
+func TestCache_MaxCost(t *testing.T) {
+	cache := NewCache(2, 10, WithMaxCost(1000), WithSizer(func(key string, value interface{}) int64 {
+		return int64(len(value.([]byte)))
+	}))
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), make([]byte, 100))
+	}
+	cache.SetWithCost("big", "v", 400)
+
+	var cost int64
+	cache.Scan(func(key string, value interface{}, expAt int64) {
+		if key == "big" {
+			cost += 400
+		} else {
+			cost += int64(len(value.([]byte)))
+		}
+	})
+	if cost > 1000 {
+		t.Fatal("cache cost should be limited to 1000, got ", cost)
+	}
+}
+
+func TestCache_LoadAsyncWithStale(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	refreshErr := make(chan error, 1)
+	cache := NewCache(2, 10, WithClock(clock), WithRefreshErrorHandler(func(key string, err error) {
+		refreshErr <- err
+	}))
+
+	// 过期未超过maxStale，返回旧value并异步加载
+	cache.SetEx("t1", 1, time.Second)
+	cache.SetEx("t2", 1, time.Second)
+	clock.Set(clock.Now().Add(2 * time.Second))
+	errLoad := errors.New("load failed")
+	val, err := cache.LoadAsyncWithStale("t1", func() (interface{}, error) {
+		return nil, errLoad
+	}, time.Minute, time.Minute)
+	if err != nil || val != 1 {
+		t.Fatal("stale value should be returned")
+	}
+	select {
+	case err := <-refreshErr:
+		if !errors.Is(err, errLoad) {
+			t.Fatal("refresh error should be errLoad, got ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refresh error should be reported")
+	}
+
+	// 超过maxStale同步加载
+	val, err = cache.LoadAsyncWithStale("t2", func() (interface{}, error) {
+		return 2, nil
+	}, time.Minute, 500*time.Millisecond)
+	if err != nil || val != 2 {
+		t.Fatal("value should be loaded synchronously, got ", val)
+	}
+}
+
+func TestCache_RefreshAhead(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	cache := NewCache(2, 10, WithClock(clock))
+	start := clock.Now()
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			<-release
+		}
+		return n, nil
+	}
+
+	val, _ := cache.LoadWithEx("t1", fn, 40*time.Second, WithRefreshAhead(0.5))
+	if val != int32(1) {
+		t.Fatal("first load should be 1")
+	}
+
+	// 未到刷新时间
+	clock.Set(start.Add(19 * time.Second))
+	val, _ = cache.LoadWithEx("t1", fn, 40*time.Second, WithRefreshAhead(0.5))
+	if val != int32(1) || atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("should not refresh before ratio")
+	}
+
+	// 超过ttl的一半，刷新完成前并发读取只触发一次刷新
+	clock.Set(start.Add(25 * time.Second))
+	var w sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			val, _ := cache.LoadWithEx("t1", fn, 40*time.Second, WithRefreshAhead(0.5))
+			if val != int32(1) {
+				t.Error("old value should be returned while refreshing")
+			}
+		}()
+	}
+	w.Wait()
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if val, _ := cache.Get("t1"); val == int32(2) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("t1 should be refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatal("refresh should be triggered once, calls: ", n)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl != 40*time.Second {
+		t.Fatal("refreshed t1 should have a new ttl, got ", ttl)
+	}
+}
+
+func TestCache_XFetch(t *testing.T) {
+	cache := NewCache(2, 10)
+	var calls int32
+	fn := func() (interface{}, error) {
+		time.Sleep(2 * time.Millisecond)
+		return atomic.AddInt32(&calls, 1), nil
+	}
+
+	// beta极小时不会提前加载
+	for i := 0; i < 10; i++ {
+		_, _ = cache.LoadWithEx("t1", fn, time.Minute, WithXFetch(1e-9))
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatal("should not recompute early, calls: ", n)
+	}
+
+	// beta极大时几乎总是提前加载
+	for i := 0; i < 10; i++ {
+		_, _ = cache.LoadWithEx("t1", fn, time.Minute, WithXFetch(1e9))
+	}
+	if n := atomic.LoadInt32(&calls); n < 5 {
+		t.Fatal("should recompute early, calls: ", n)
+	}
+
+	// 没有记录加载耗时的key不提前加载
+	o := newLoadOptions([]LoadOption{WithXFetch(1e9)})
+	if o.shouldRecompute(time.Now().UnixNano(), time.Now().Add(time.Minute).UnixNano(), entryMeta{ttl: int64(time.Minute)}) {
+		t.Fatal("key without delta should not recompute")
+	}
+}
+
+type ctxKey struct{}
+
+func TestCache_LoadCtx(t *testing.T) {
+	cache := NewCache(2, 10)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return ctx.Value(ctxKey{}), nil
+	}
+
+	// 调用方超时放弃等待，加载继续执行
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "v1"), 5*time.Millisecond)
+	defer cancel()
+	_, err := cache.LoadCtx(ctx, "t1", fn, time.Minute)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("load should return deadline exceeded, got ", err)
+	}
+	<-started
+
+	// 其它调用方共享同一次加载
+	done := make(chan interface{})
+	go func() {
+		val, _ := cache.LoadCtx(context.Background(), "t1", func(ctx context.Context) (interface{}, error) {
+			return "v2", nil
+		}, time.Minute)
+		done <- val
+	}()
+	time.Sleep(time.Millisecond)
+	close(release)
+	if val := <-done; val != "v1" {
+		t.Fatal("shared load value should be v1, got ", val)
+	}
+	if val, err := cache.Get("t1"); err != nil || val != "v1" {
+		t.Fatal("t1 should be cached")
+	}
+}
+
+func TestCache_LoadTimeout(t *testing.T) {
+	cache := NewCache(2, 10, WithLoadTimeout(5*time.Millisecond))
+	_, err := cache.LoadCtx(context.Background(), "t1", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, -1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("load should be canceled by timeout, got ", err)
+	}
+	if _, err := cache.Get("t1"); !ErrIsNotFound(err) {
+		t.Fatal("t1 should not be cached")
+	}
+}
+
 var _kvs = map[string]interface{}{
 	"t1":  []byte("hello"),
 	"t2":  "world",
@@ -69,3 +371,181 @@ func TestCache_SaveBaseType(t *testing.T) {
 		fmt.Println(key, "-----", value, "-----", expAt)
 	})
 }
+
+func TestCache_TTL(t *testing.T) {
+	cache := NewCache(2, 10)
+	cache.Set("t1", 1)
+	cache.SetEx("t2", 2, time.Hour)
+
+	if ttl, err := cache.TTL("t1"); err != nil || ttl != NoExpiration {
+		t.Fatal("t1 should not expire, got ", ttl, err)
+	}
+	if ttl, err := cache.TTL("t2"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Fatal("t2 ttl should be about 1h, got ", ttl, err)
+	}
+	if _, err := cache.TTL("t3"); !ErrIsNotFound(err) {
+		t.Fatal("t3 should not exist")
+	}
+
+	if !cache.Expire("t1", time.Minute) {
+		t.Fatal("t1 should exist")
+	}
+	if ttl, _ := cache.TTL("t1"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatal("t1 ttl should be about 1m, got ", ttl)
+	}
+	if !cache.Persist("t2") {
+		t.Fatal("t2 should exist")
+	}
+	if ttl, _ := cache.TTL("t2"); ttl != NoExpiration {
+		t.Fatal("t2 should not expire, got ", ttl)
+	}
+	if cache.Expire("t3", time.Minute) || cache.Persist("t3") || cache.Touch("t3") {
+		t.Fatal("t3 should not exist")
+	}
+
+	// 过期的key不能修改过期时间
+	cache.ExpireAt("t1", time.Now().Add(-time.Second))
+	if cache.Persist("t1") {
+		t.Fatal("t1 should be expired")
+	}
+	if _, err := cache.Get("t1"); !ErrIsNotFound(err) {
+		t.Fatal("t1 should be expired")
+	}
+}
+
+func TestCache_Touch(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	cache := NewCache(2, 10, WithClock(clock))
+	start := clock.Now()
+	cache.SetEx("t1", 1, 100*time.Second)
+	cache.Set("t2", 2)
+	cache.SetWithCost("t3", 3, 1)
+
+	clock.Set(start.Add(60 * time.Second))
+	if !cache.Touch("t1") {
+		t.Fatal("t1 should be touched")
+	}
+	clock.Set(start.Add(159 * time.Second))
+	if _, err := cache.Get("t1"); err != nil {
+		t.Fatal("t1 should be renewed")
+	}
+	clock.Set(start.Add(160 * time.Second))
+	if _, err := cache.Get("t1"); !ErrIsNotFound(err) {
+		t.Fatal("t1 should be expired 100s after touch")
+	}
+	if cache.Touch("t2") || cache.Touch("t3") {
+		t.Fatal("keys without ttl should not be touched")
+	}
+
+	// Touch按Expire设置的ttl续期
+	cache.Expire("t2", time.Hour)
+	cache.ExpireAt("t2", clock.Now().Add(time.Minute))
+	cache.Touch("t2")
+	if ttl, _ := cache.TTL("t2"); ttl != time.Hour {
+		t.Fatal("t2 should be renewed by 1h, got ", ttl)
+	}
+}
+
+func TestCacheTimer_Expire(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := newManualClock(start)
+	var mu sync.Mutex
+	expired := make(map[string]bool)
+	cache := NewCacheWithGC(2, 10, time.Second, WithClock(clock),
+		WithRemovalListener(func(key string, value interface{}, reason RemovalReason) {
+			mu.Lock()
+			expired[key] = reason == RemovalExpired
+			mu.Unlock()
+		}))
+	defer cache.Close()
+	ct := cache.s.(*cacheTimer)
+
+	cache.Set("t1", 1)
+	cache.SetEx("t2", 2, time.Hour)
+	cache.SetEx("t3", 3, 2*time.Second)
+	cache.Expire("t1", 2*time.Second)
+	cache.ExpireAt("t2", start.Add(2*time.Second))
+	cache.Persist("t3")
+	if n := ct.expirer.(*wheelExpirer).Len(); n != 2 {
+		t.Fatal("timer should have 2 nodes, got ", n)
+	}
+
+	clock.Set(start.Add(3 * time.Second))
+	clock.Tick(start.Add(3 * time.Second))
+	mu.Lock()
+	defer mu.Unlock()
+	if !expired["t1"] || !expired["t2"] {
+		t.Fatal("t1 and t2 should be expired by timer: ", expired)
+	}
+	if _, ok := expired["t3"]; ok {
+		t.Fatal("t3 should not be expired")
+	}
+}
+
+func TestCache_ExpireAtPast(t *testing.T) {
+	cache := NewCacheWithGC(2, 10, time.Minute)
+	defer cache.Close()
+
+	cache.SetEx("t1", 1, time.Hour)
+	cache.Set("t2", 2)
+	if !cache.ExpireAt("t1", time.Now().Add(-time.Second)) {
+		t.Fatal("t1 should be expired")
+	}
+	if !cache.ExpireAt("t2", time.Time{}) {
+		t.Fatal("t2 should be expired")
+	}
+	for _, key := range []string{"t1", "t2"} {
+		if _, err := cache.Get(key); !ErrIsNotFound(err) {
+			t.Fatalf("%s should be deleted", key)
+		}
+	}
+	if n := cache.s.(*cacheTimer).expirer.(*wheelExpirer).Len(); n != 0 {
+		t.Fatal("timer should be empty, got ", n)
+	}
+	if cache.ExpireAt("t3", time.Time{}) {
+		t.Fatal("t3 should not exist")
+	}
+}
+
+func TestCache_SetIdle(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	cache := NewCache(2, 10, WithClock(clock))
+	start := clock.Now()
+	cache.SetIdle("t1", 1, 50*time.Second)
+	cache.SetIdleWithLimit("t2", 2, 50*time.Second, 80*time.Second)
+	cache.SetIdle("t3", 3, 50*time.Second)
+
+	// 在滑动过期时间内读取t1及t2
+	for i := 1; i <= 2; i++ {
+		clock.Set(start.Add(time.Duration(i) * 30 * time.Second))
+		if _, err := cache.Get("t1"); err != nil {
+			t.Fatal("t1 should be extended by Get")
+		}
+		if _, err := cache.Get("t2"); err != nil {
+			t.Fatal("t2 should be extended by Get")
+		}
+	}
+	if _, err := cache.Get("t3"); !ErrIsNotFound(err) {
+		t.Fatal("t3 should be expired")
+	}
+
+	// 读取延长的过期时间不超过limit
+	clock.Set(start.Add(79 * time.Second))
+	if ttl, _ := cache.TTL("t2"); ttl != time.Second {
+		t.Fatal("t2 ttl should be limited, got ", ttl)
+	}
+	clock.Set(start.Add(80 * time.Second))
+	if _, err := cache.Get("t2"); !ErrIsNotFound(err) {
+		t.Fatal("t2 should be expired by limit")
+	}
+
+	if ttl, _ := cache.TTL("t1"); ttl != 30*time.Second {
+		t.Fatal("t1 ttl should be idle since last read, got ", ttl)
+	}
+	// 写入固定过期时间后不再滑动过期
+	cache.Expire("t1", time.Hour)
+	cache.Get("t1")
+	if ttl, _ := cache.TTL("t1"); ttl != time.Hour {
+		t.Fatal("t1 ttl should be 1h, got ", ttl)
+	}
+}
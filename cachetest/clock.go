@@ -0,0 +1,185 @@
+// Package cachetest 提供测试缓存时使用的工具
+package cachetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/welllog/cache"
+)
+
+var _ cache.Clock = (*FakeClock)(nil)
+
+// FakeClock 手动推进的时钟，Advance在expirer处理完所有到期的tick及定时器后才返回，Advance与Set不能并发调用
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []*fakeTimer
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) cache.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{
+		c:    make(chan time.Time),
+		stop: make(chan struct{}),
+		d:    d,
+		next: f.now.Add(d),
+	}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// AfterFunc f在Advance推进到d后同步调用，d <= 0 时在下一次Advance时调用
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) cache.Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{clock: f, at: f.now.Add(d), f: fn}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance 推进时间d，按顺序发送期间到期的所有tick及调用到期的定时器，并等待处理完成
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	tickers := make([]*fakeTicker, len(f.tickers))
+	copy(tickers, f.tickers)
+	f.mu.Unlock()
+
+	for {
+		// 找到最早到期的tick
+		var next *fakeTicker
+		for _, t := range tickers {
+			if t.stopped() || t.next.After(end) {
+				continue
+			}
+			if next == nil || t.next.Before(next.next) {
+				next = t
+			}
+		}
+		// 不晚于tick到期的定时器先调用，定时器中可以创建新的定时器
+		if timer := f.popTimer(end, next); timer != nil {
+			timer.f()
+			continue
+		}
+		if next == nil {
+			break
+		}
+
+		tick := next.next
+		next.next = tick.Add(next.d)
+		f.mu.Lock()
+		f.now = tick
+		f.mu.Unlock()
+		next.send(tick)
+		// 再次发送相同的时间，expirer不处理不晚于上一次的tick，发送成功说明上一个tick已处理完成
+		next.send(tick)
+	}
+
+	f.mu.Lock()
+	f.now = end
+	f.mu.Unlock()
+}
+
+// popTimer 取出最早的不晚于end及tick的定时器，并将当前时间推进到其到期时间
+func (f *FakeClock) popTimer(end time.Time, tick *fakeTicker) *fakeTimer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pos := -1
+	for i, t := range f.timers {
+		if t.at.After(end) || (tick != nil && t.at.After(tick.next)) {
+			continue
+		}
+		if pos < 0 || t.at.Before(f.timers[pos].at) {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return nil
+	}
+	t := f.timers[pos]
+	f.timers = append(f.timers[:pos], f.timers[pos+1:]...)
+	if t.at.After(f.now) {
+		f.now = t.at
+	}
+	return t
+}
+
+// Set 设置当前时间，不发送tick，也不调用定时器
+func (f *FakeClock) Set(now time.Time) {
+	f.mu.Lock()
+	f.now = now
+	for _, t := range f.tickers {
+		t.next = now.Add(t.d)
+	}
+	f.mu.Unlock()
+}
+
+type fakeTicker struct {
+	c    chan time.Time
+	stop chan struct{}
+	once sync.Once
+	d    time.Duration
+	next time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+	})
+}
+
+func (t *fakeTicker) send(now time.Time) {
+	select {
+	case t.c <- now:
+	case <-t.stop:
+	}
+}
+
+func (t *fakeTicker) stopped() bool {
+	select {
+	case <-t.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+// Stop 定时器尚未调用时返回true
+func (t *fakeTimer) Stop() bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, timer := range f.timers {
+		if timer == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package cachetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/welllog/cache"
+)
+
+func TestFakeClock_Expire(t *testing.T) {
+	strategies := []struct {
+		name     string
+		strategy cache.ExpiryStrategy
+	}{
+		{"wheel", cache.ExpireByTimingWheel},
+		{"heap", cache.ExpireByHeap},
+		{"sampling", cache.ExpireBySampling},
+	}
+	for _, st := range strategies {
+		t.Run(st.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			removed := make(map[string]cache.RemovalReason)
+			c := cache.NewCacheWithGC(2, 10, time.Second, cache.WithClock(clock), cache.WithExpiryStrategy(st.strategy),
+				cache.WithRemovalListener(func(key string, value interface{}, reason cache.RemovalReason) {
+					removed[key] = reason
+				}))
+			defer c.Close()
+
+			c.SetEx("t1", 1, 3*time.Second)
+			c.SetEx("t2", 2, 10*time.Second)
+
+			clock.Advance(2 * time.Second)
+			if _, err := c.Get("t1"); err != nil {
+				t.Fatal("t1 should not be expired")
+			}
+
+			// expirer按tick同步清理过期key
+			clock.Advance(2 * time.Second)
+			if removed["t1"] != cache.RemovalExpired {
+				t.Fatal("t1 should be expired by expirer")
+			}
+			if _, ok := removed["t2"]; ok {
+				t.Fatal("t2 should not be expired")
+			}
+
+			clock.Advance(10 * time.Second)
+			if removed["t2"] != cache.RemovalExpired {
+				t.Fatal("t2 should be expired by expirer")
+			}
+		})
+	}
+}
+
+func TestFakeClock_Heap(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	removed := make(map[string]time.Time)
+	c := cache.NewCacheWithGC(2, 10, time.Hour, cache.WithClock(clock), cache.WithExpiryStrategy(cache.ExpireByHeap),
+		cache.WithRemovalListener(func(key string, value interface{}, reason cache.RemovalReason) {
+			if reason == cache.RemovalExpired {
+				removed[key] = clock.Now()
+			}
+		}))
+	defer c.Close()
+
+	// 之后加入的更早的过期时间重新设置定时器，在过期时间删除而不是按gcInterval
+	start := clock.Now()
+	c.SetEx("late", 1, 10*time.Hour)
+	c.SetEx("t1", 1, 1500*time.Millisecond)
+	c.SetEx("t2", 2, 2500*time.Millisecond)
+	c.SetEx("t3", 3, 2500*time.Millisecond)
+	c.Del("t3")
+
+	clock.Advance(3 * time.Second)
+	if at := removed["t1"]; !at.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Fatal("t1 should be expired at deadline, got ", at.Sub(start))
+	}
+	if at := removed["t2"]; !at.Equal(start.Add(2500 * time.Millisecond)) {
+		t.Fatal("t2 should be expired at deadline, got ", at.Sub(start))
+	}
+	if _, ok := removed["t3"]; ok {
+		t.Fatal("deleted t3 should not be expired")
+	}
+
+	clock.Advance(10 * time.Hour)
+	if at := removed["late"]; !at.Equal(start.Add(10 * time.Hour)) {
+		t.Fatal("late should be expired at deadline, got ", at.Sub(start))
+	}
+}
+
+func TestFakeClock_LazyExpire(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := cache.NewCache(2, 10, cache.WithClock(clock))
+
+	c.SetEx("t1", 1, time.Second)
+	clock.Set(clock.Now().Add(999 * time.Millisecond))
+	if _, err := c.Get("t1"); err != nil {
+		t.Fatal("t1 should not be expired")
+	}
+	clock.Advance(time.Millisecond)
+	if _, err := c.Get("t1"); !cache.ErrIsNotFound(err) {
+		t.Fatal("t1 should be expired")
+	}
+}
+
+func TestFakeClock_Stop(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := cache.NewCacheWithGC(2, 10, time.Second, cache.WithClock(clock))
+	c.Close()
+
+	// 关闭后推进时间不阻塞
+	clock.Advance(10 * time.Second)
+}
+
+func TestFakeClock_ExpireLongTTL(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewFakeClock(start)
+	removed := make(map[string]time.Time)
+	c := cache.NewCacheWithGC(2, 10, time.Second, cache.WithClock(clock),
+		cache.WithRemovalListener(func(key string, value interface{}, reason cache.RemovalReason) {
+			removed[key] = clock.Now()
+		}))
+	defer c.Close()
+
+	ttls := map[string]time.Duration{
+		"t1": 45 * time.Minute,
+		"t2": 2*time.Hour + 30*time.Second,
+		"t3": 5 * time.Hour,
+		"t4": 3*24*time.Hour + 7*time.Minute,   // 第四层时间轮
+		"t5": 16*24*time.Hour + 30*time.Second, // 第五层时间轮
+	}
+	for key, ttl := range ttls {
+		c.SetEx(key, 1, ttl)
+	}
+
+	clock.Advance(16*24*time.Hour + time.Minute)
+	for key, ttl := range ttls {
+		at, ok := removed[key]
+		if !ok {
+			t.Fatalf("%s should be expired by timer", key)
+		}
+		// 时间轮级联后过期精度为一个tick
+		if delay := at.Sub(start.Add(ttl)); delay < 0 || delay > time.Second {
+			t.Fatalf("%s expired with delay %s", key, delay)
+		}
+	}
+}
+
+func TestFakeClock_Idle(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	removed := make(map[string]time.Time)
+	c := cache.NewCacheWithGC(2, 10, time.Second, cache.WithClock(clock),
+		cache.WithRemovalListener(func(key string, value interface{}, reason cache.RemovalReason) {
+			if reason == cache.RemovalExpired {
+				removed[key] = clock.Now()
+			}
+		}))
+	defer c.Close()
+
+	start := clock.Now()
+	c.SetIdle("t1", 1, 10*time.Second)
+	c.SetIdleWithLimit("t2", 2, 10*time.Second, 15*time.Second)
+	c.SetIdle("t3", 3, 10*time.Second)
+
+	clock.Advance(8 * time.Second)
+	if _, err := c.Get("t1"); err != nil {
+		t.Fatal("t1 should not be expired")
+	}
+	if _, err := c.Get("t2"); err != nil {
+		t.Fatal("t2 should not be expired")
+	}
+
+	// 时间轮在最初的过期时间重新安排读取过的key，过期精度为一个tick
+	expiredIn := func(key string, d time.Duration) bool {
+		at, ok := removed[key]
+		return ok && !at.Before(start.Add(d)) && !at.After(start.Add(d+time.Second))
+	}
+	clock.Advance(8 * time.Second)
+	if !expiredIn("t3", 10*time.Second) {
+		t.Fatal("t3 should be expired at 10s, got ", removed["t3"].Sub(start))
+	}
+	if !expiredIn("t2", 15*time.Second) {
+		t.Fatal("t2 should be expired at limit, got ", removed["t2"].Sub(start))
+	}
+	if _, ok := removed["t1"]; ok {
+		t.Fatal("t1 should not be expired")
+	}
+
+	clock.Advance(4 * time.Second)
+	if !expiredIn("t1", 18*time.Second) {
+		t.Fatal("t1 should be expired at 18s, got ", removed["t1"].Sub(start))
+	}
+}
+
+func TestFakeClock_LoadAsyncWithStale(t *testing.T) {
+	strategies := []struct {
+		name     string
+		strategy cache.ExpiryStrategy
+	}{
+		{"wheel", cache.ExpireByTimingWheel},
+		{"heap", cache.ExpireByHeap},
+		{"sampling", cache.ExpireBySampling},
+	}
+	for _, st := range strategies {
+		t.Run(st.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			var mu sync.Mutex
+			expired := make(map[string]bool)
+			c := cache.NewCacheWithGC(2, 10, time.Second, cache.WithClock(clock), cache.WithExpiryStrategy(st.strategy),
+				cache.WithRemovalListener(func(key string, value interface{}, reason cache.RemovalReason) {
+					if reason == cache.RemovalExpired {
+						mu.Lock()
+						expired[key] = true
+						mu.Unlock()
+					}
+				}))
+			defer c.Close()
+			isExpired := func(key string) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return expired[key]
+			}
+
+			loaded := make(chan struct{}, 1)
+			load := func(v int) cache.LoadFunc {
+				return func() (interface{}, error) {
+					loaded <- struct{}{}
+					return v, nil
+				}
+			}
+			if v, _ := c.LoadAsyncWithStale("k", load(1), 5*time.Second, time.Minute); v != 1 {
+				t.Fatal("k should be loaded")
+			}
+			<-loaded
+			if v, _ := c.LoadAsyncWithStale("gone", load(1), 5*time.Second, time.Minute); v != 1 {
+				t.Fatal("gone should be loaded")
+			}
+			<-loaded
+			if v, _ := c.LoadAsyncWithEx("forever", load(1), 5*time.Second); v != 1 {
+				t.Fatal("forever should be loaded")
+			}
+			<-loaded
+
+			// 过期的key在maxStale内不被expirer删除，仍返回旧value并异步加载
+			clock.Advance(30 * time.Second)
+			if isExpired("k") || isExpired("gone") {
+				t.Fatal("stale keys should not be removed by expirer")
+			}
+			if _, err := c.Get("k"); !cache.ErrIsNotFound(err) {
+				t.Fatal("stale k should be expired for Get")
+			}
+			if v, _ := c.LoadAsyncWithStale("k", load(2), 5*time.Second, time.Minute); v != 1 {
+				t.Fatal("stale value should be returned, got ", v)
+			}
+			<-loaded
+			for {
+				if v, _ := c.Get("k"); v == 2 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			// 超过maxStale后被expirer删除，LoadAsyncWithEx加载的key一直保留
+			clock.Advance(time.Minute)
+			if !isExpired("gone") {
+				t.Fatal("gone should be removed after maxStale")
+			}
+			if isExpired("k") {
+				t.Fatal("refreshed k should not be removed")
+			}
+			clock.Advance(time.Hour)
+			if !isExpired("k") {
+				t.Fatal("k should be removed after maxStale")
+			}
+			if isExpired("forever") {
+				t.Fatal("forever should not be removed")
+			}
+			if v, _ := c.LoadAsyncWithEx("forever", load(2), 5*time.Second); v != 1 {
+				t.Fatal("forever stale value should be returned, got ", v)
+			}
+			<-loaded
+		})
+	}
+}
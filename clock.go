@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_realClock Clock = realClock{}
+	_          Clock = (*CoarseClock)(nil)
+)
+
+// Clock 时间源，用于过期时间的计算及expirer的推进
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc d后在单独的goroutine中调用f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker 按固定间隔从C发送当前时间，推进清理过期key的expirer
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer AfterFunc返回的定时器，Stop在f执行前停止时返回true
+type Timer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{t: time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r realTicker) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r realTicker) Stop() {
+	r.t.Stop()
+}
+
+// CoarseClock 由单独的goroutine按精度定期更新的时钟，Now只需原子读取，避免频繁调用time.Now
+// Stop后Now改为返回系统时间
+type CoarseClock struct {
+	now     int64
+	stopped int32
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewCoarseClock precision <= 0 时为1毫秒，不再使用时需调用Stop
+func NewCoarseClock(precision time.Duration) *CoarseClock {
+	if precision <= 0 {
+		precision = time.Millisecond
+	}
+	c := &CoarseClock{
+		now:  time.Now().UnixNano(),
+		stop: make(chan struct{}),
+	}
+	go c.run(precision)
+	return c
+}
+
+func (c *CoarseClock) Now() time.Time {
+	if atomic.LoadInt32(&c.stopped) == 1 {
+		return time.Now()
+	}
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+func (c *CoarseClock) NewTicker(d time.Duration) Ticker {
+	return _realClock.NewTicker(d)
+}
+
+func (c *CoarseClock) AfterFunc(d time.Duration, f func()) Timer {
+	return _realClock.AfterFunc(d, f)
+}
+
+func (c *CoarseClock) Stop() {
+	c.once.Do(func() {
+		atomic.StoreInt32(&c.stopped, 1)
+		close(c.stop)
+	})
+}
+
+func (c *CoarseClock) run(precision time.Duration) {
+	ticker := time.NewTicker(precision)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			atomic.StoreInt64(&c.now, time.Now().UnixNano())
+		}
+	}
+}
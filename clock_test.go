@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoarseClock(t *testing.T) {
+	c := NewCoarseClock(time.Millisecond)
+	start := c.Now()
+	if d := time.Since(start); d < 0 || d > 100*time.Millisecond {
+		t.Fatal("coarse clock should be close to time.Now, diff: ", d)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if !c.Now().After(start) {
+		t.Fatal("coarse clock should be updated")
+	}
+
+	c.Stop()
+	c.Stop()
+	before := time.Now()
+	if c.Now().Before(before) {
+		t.Fatal("stopped coarse clock should return time.Now")
+	}
+}
+
+func TestCache_CoarseClock(t *testing.T) {
+	before := runtime.NumGoroutine()
+	cache := NewCacheWithGC(2, 10, time.Millisecond, WithCoarseClock(time.Millisecond))
+	cache.SetEx("t1", 1, 5*time.Millisecond)
+	if _, err := cache.Get("t1"); err != nil {
+		t.Fatal("t1 should exists")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := cache.Get("t1"); !ErrIsNotFound(err) {
+		t.Fatal("t1 should be expired")
+	}
+	cache.Close()
+
+	var after int
+	for i := 0; i < 100; i++ {
+		after = runtime.NumGoroutine()
+		if after <= before {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if after > before {
+		t.Fatalf("coarse clock goroutine leaked, before: %d, after: %d", before, after)
+	}
+}
+
+// manualClock 手动设置Now及发送tick的时钟，Now可以落后于tick，模拟CoarseClock
+type manualClock struct {
+	now int64
+	c   chan time.Time
+}
+
+func newManualClock(now time.Time) *manualClock {
+	return &manualClock{now: now.UnixNano(), c: make(chan time.Time)}
+}
+
+func (m *manualClock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&m.now))
+}
+
+func (m *manualClock) Set(now time.Time) {
+	atomic.StoreInt64(&m.now, now.UnixNano())
+}
+
+func (m *manualClock) NewTicker(time.Duration) Ticker {
+	return m
+}
+
+// AfterFunc 不会调用f，manualClock只用于时间轮
+func (m *manualClock) AfterFunc(time.Duration, func()) Timer {
+	return stoppedTimer{}
+}
+
+type stoppedTimer struct{}
+
+func (stoppedTimer) Stop() bool {
+	return true
+}
+
+func (m *manualClock) C() <-chan time.Time {
+	return m.c
+}
+
+func (m *manualClock) Stop() {}
+
+// Tick 发送tick，再次发送相同的时间不推进时间轮，发送成功说明上一个tick已处理完成
+func (m *manualClock) Tick(now time.Time) {
+	m.c <- now
+	m.c <- now
+}
+
+func TestCacheTimer_LaggingClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := newManualClock(start)
+	cache := NewCacheWithGC(2, 10, time.Second, WithClock(clock))
+	defer cache.Close()
+	timer := cache.s.(*cacheTimer).expirer.(*wheelExpirer)
+
+	cache.SetEx("t1", 1, 1500*time.Millisecond)
+	cache.SetEx("t2", 2, 2500*time.Millisecond)
+	cache.SetIdle("t3", 3, 1500*time.Millisecond)
+
+	// 时钟落后于tick时按tick的时间删除到期的key
+	clock.Set(start.Add(time.Second))
+	clock.Tick(start.Add(2 * time.Second))
+	for _, key := range []string{"t1", "t3"} {
+		if _, _, ok := cache.s.Peek(cache.s.Index(key), key); ok {
+			t.Fatalf("%s should be deleted at tick time", key)
+		}
+	}
+
+	// 到期时未删除的key重新加入时间轮
+	cache.s.(*cacheTimer).sharers[cache.s.Index("t2")].Set("t2", 2, start.Add(4500*time.Millisecond).UnixNano())
+	clock.Set(start.Add(3 * time.Second))
+	clock.Tick(start.Add(3 * time.Second))
+	if _, _, ok := cache.s.Peek(cache.s.Index("t2"), "t2"); !ok {
+		t.Fatal("t2 should not be deleted")
+	}
+	if n := timer.Len(); n != 1 {
+		t.Fatal("t2 should be rescheduled, got ", n)
+	}
+
+	clock.Set(start.Add(5 * time.Second))
+	clock.Tick(start.Add(5 * time.Second))
+	if _, _, ok := cache.s.Peek(cache.s.Index("t2"), "t2"); ok {
+		t.Fatal("t2 should be deleted")
+	}
+	if n := timer.Len(); n != 0 {
+		t.Fatal("timer should be empty, got ", n)
+	}
+}
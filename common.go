@@ -1,24 +1,58 @@
 package cache
 
 import (
+	"context"
 	"errors"
+	"time"
 )
 
 const (
 	_maxShareds = 1 << 10 // 最大分片数量，必须为2^x
 	_prime32    = uint32(16777619)
+	_EMPTY_STR  = ""
 )
 
+// NoExpiration TTL返回的不过期key的剩余时间
+const NoExpiration time.Duration = -1
+
 var (
 	ErrNil = errors.New("cache missing")
 )
 
 type LoadFunc func() (interface{}, error)
 
+// LoadCtxFunc ctx在调用方全部放弃等待后不会取消，只在超过WithLoadTimeout设置的时间后取消
+type LoadCtxFunc func(ctx context.Context) (interface{}, error)
+
+// Sizer 计算key的成本
+type Sizer func(key string, value interface{}) int64
+
+// DefaultSizer 成本为key与value的字节数，value为基础数据类型以外的类型时按interface大小计算
+func DefaultSizer(key string, value interface{}) int64 {
+	return int64(len(key)) + baseTypeSize(value)
+}
+
 func ErrIsNotFound(err error) bool {
 	return errors.Is(err, ErrNil)
 }
 
+// detachedContext 保留parent中的value，但不继承parent的取消及截止时间
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
 func fnv32(str string) uint32 {
 	hash := uint32(2166136261)
 	for i := 0; i < len(str); i++ {
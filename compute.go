@@ -0,0 +1,120 @@
+package cache
+
+import "time"
+
+// GetOrSet key存在时返回已有的value及true，否则按ttl写入value并返回value及false，ttl < 0 表示不过期
+// 缓存的加载错误视为key不存在
+func (c *Cache) GetOrSet(key string, value interface{}, ttl time.Duration) (interface{}, bool) {
+	actual, loaded := value, false
+	expAt, meta := c.expiry(ttl)
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if exists(item) {
+			actual, loaded = item.value, true
+			return nil, 0, entryMeta{}, computeNone
+		}
+		return value, expAt, meta, computeSet
+	})
+	return actual, loaded
+}
+
+// Update 在分片写锁内读取并修改key，fn返回keep为false时删除key，否则写入fn返回的value
+// 已存在的key保留原来的过期时间，新写入的key不过期，返回写入的value及key是否保留，fn中不能调用缓存的方法
+func (c *Cache) Update(key string, fn func(old interface{}, exists bool) (new interface{}, keep bool)) (interface{}, bool) {
+	return c.compute(key, fn, func(item *entry) (int64, entryMeta) {
+		if exists(item) {
+			return item.expAt, item.meta
+		}
+		return -1, entryMeta{}
+	})
+}
+
+// Compute 与Update相同，但按ttl写入fn返回的value，ttl < 0 表示不过期
+func (c *Cache) Compute(key string, fn func(old interface{}, exists bool) (new interface{}, keep bool), ttl time.Duration) (interface{}, bool) {
+	expAt, meta := c.expiry(ttl)
+	return c.compute(key, fn, func(*entry) (int64, entryMeta) {
+		return expAt, meta
+	})
+}
+
+// CompareAndSwap key的value等于old时替换为new并保留原来的过期时间，value或old为不可比较的类型（如[]byte、map、slice）时返回false
+func (c *Cache) CompareAndSwap(key string, old, new interface{}) bool {
+	var swapped bool
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if !exists(item) || !equal(item.value, old) {
+			return nil, 0, entryMeta{}, computeNone
+		}
+		swapped = true
+		return new, item.expAt, item.meta, computeSet
+	})
+	return swapped
+}
+
+// CompareAndDelete key的value等于old时删除key，value或old为不可比较的类型（如[]byte、map、slice）时返回false
+func (c *Cache) CompareAndDelete(key string, old interface{}) bool {
+	var deleted bool
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if !exists(item) || !equal(item.value, old) {
+			return nil, 0, entryMeta{}, computeNone
+		}
+		deleted = true
+		return nil, 0, entryMeta{}, computeDelete
+	})
+	return deleted
+}
+
+// equal 任意一方不可比较时返回false，包括类型可比较但字段中的接口值不可比较的情况，避免==时panic
+func equal(a, b interface{}) (eq bool) {
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return a == b
+}
+
+// compute expiry返回写入的过期时间及元数据
+func (c *Cache) compute(key string, fn func(old interface{}, exists bool) (interface{}, bool),
+	expiry func(item *entry) (int64, entryMeta)) (interface{}, bool) {
+	var (
+		value interface{}
+		keep  bool
+	)
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		var old interface{}
+		ok := exists(item)
+		if ok {
+			old = item.value
+		}
+		value, keep = fn(old, ok)
+		if !keep {
+			if item == nil {
+				return nil, 0, entryMeta{}, computeNone
+			}
+			return nil, 0, entryMeta{}, computeDelete
+		}
+		expAt, meta := expiry(item)
+		return value, expAt, meta, computeSet
+	})
+	if !keep {
+		return nil, false
+	}
+	return value, true
+}
+
+// expiry 按ttl计算写入的过期时间及元数据，与SetEx相同
+func (c *Cache) expiry(ttl time.Duration) (int64, entryMeta) {
+	if ttl < 0 {
+		return -1, entryMeta{}
+	}
+	ttl = c.jitter.Apply(ttl)
+	return c.clock.Now().UnixNano() + int64(ttl), entryMeta{ttl: int64(ttl)}
+}
+
+// exists 缓存的加载错误视为key不存在
+func exists(item *entry) bool {
+	if item == nil {
+		return false
+	}
+	_, negative := negativeErr(item.value)
+	return !negative
+}
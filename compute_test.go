@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_GetOrSet(t *testing.T) {
+	cache := NewCache(2, 10)
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		stored int
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			actual, loaded := cache.GetOrSet("t1", i, time.Hour)
+			if !loaded {
+				mu.Lock()
+				stored++
+				mu.Unlock()
+				if actual != i {
+					t.Error("actual should be the stored value")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if stored != 1 {
+		t.Fatal("only one goroutine should store, got ", stored)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl <= 59*time.Minute {
+		t.Fatal("t1 ttl should be 1h, got ", ttl)
+	}
+
+	// 过期的key及缓存的加载错误视为不存在
+	cache.SetEx("t2", 1, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	if actual, loaded := cache.GetOrSet("t2", 2, -1); loaded || actual != 2 {
+		t.Fatal("expired t2 should be replaced")
+	}
+	cache.storeErr(cache.s.Index("t3"), "t3", ErrNil)
+	if _, loaded := cache.GetOrSet("t3", 3, -1); loaded {
+		t.Fatal("negative t3 should be replaced")
+	}
+}
+
+func TestCache_Update(t *testing.T) {
+	cache := NewCache(2, 10)
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Update("t1", incr)
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("t1"); v != 100 {
+		t.Fatal("t1 should be 100, got ", v)
+	}
+
+	// 保留原来的过期时间
+	cache.SetEx("t2", 1, time.Hour)
+	if v, ok := cache.Update("t2", incr); !ok || v != 2 {
+		t.Fatal("t2 should be 2, got ", v)
+	}
+	if ttl, _ := cache.TTL("t2"); ttl <= 59*time.Minute {
+		t.Fatal("t2 ttl should be kept, got ", ttl)
+	}
+
+	if _, ok := cache.Update("t2", func(interface{}, bool) (interface{}, bool) { return nil, false }); ok {
+		t.Fatal("t2 should be deleted")
+	}
+	if _, err := cache.Get("t2"); !ErrIsNotFound(err) {
+		t.Fatal("t2 should be deleted")
+	}
+
+	if v, ok := cache.Compute("t3", incr, time.Minute); !ok || v != 1 {
+		t.Fatal("t3 should be 1, got ", v)
+	}
+	if ttl, _ := cache.TTL("t3"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatal("t3 ttl should be 1m, got ", ttl)
+	}
+}
+
+func TestCache_Update_Panic(t *testing.T) {
+	cache := NewCache(1, 10)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("should panic")
+			}
+		}()
+		cache.Update("t1", func(interface{}, bool) (interface{}, bool) {
+			panic(errors.New("update"))
+		})
+	}()
+	// panic后释放分片锁
+	cache.Set("t1", 1)
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	cache := NewCache(2, 10)
+	cache.SetEx("t1", "a", time.Hour)
+
+	if cache.CompareAndSwap("t1", "b", "c") {
+		t.Fatal("t1 should not be swapped")
+	}
+	if !cache.CompareAndSwap("t1", "a", "c") {
+		t.Fatal("t1 should be swapped")
+	}
+	if v, _ := cache.Get("t1"); v != "c" {
+		t.Fatal("t1 should be c, got ", v)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl <= 59*time.Minute {
+		t.Fatal("t1 ttl should be kept, got ", ttl)
+	}
+	if cache.CompareAndSwap("t2", nil, "a") {
+		t.Fatal("t2 should not exist")
+	}
+
+	if cache.CompareAndDelete("t1", "a") {
+		t.Fatal("t1 should not be deleted")
+	}
+	if !cache.CompareAndDelete("t1", "c") {
+		t.Fatal("t1 should be deleted")
+	}
+	if _, err := cache.Get("t1"); !ErrIsNotFound(err) {
+		t.Fatal("t1 should be deleted")
+	}
+}
+
+func TestCache_CompareAndSwap_Uncomparable(t *testing.T) {
+	cache := NewCache(2, 10)
+	cache.Set("t1", []byte("a"))
+	cache.Set("t2", "a")
+
+	if cache.CompareAndSwap("t1", []byte("a"), "b") {
+		t.Fatal("[]byte should not be swapped")
+	}
+	if cache.CompareAndSwap("t2", map[string]int{}, "b") {
+		t.Fatal("map should not be swapped")
+	}
+	if cache.CompareAndDelete("t1", []byte("a")) {
+		t.Fatal("[]byte should not be deleted")
+	}
+	if cache.CompareAndDelete("t2", []int{1}) {
+		t.Fatal("slice should not be deleted")
+	}
+	// 类型可比较但接口字段的值不可比较
+	type holder struct{ X interface{} }
+	cache.Set("t3", holder{X: []int{1}})
+	if cache.CompareAndSwap("t3", holder{X: []int{1}}, 2) {
+		t.Fatal("holder with slice should not be swapped")
+	}
+	if cache.CompareAndDelete("t3", holder{X: []int{1}}) {
+		t.Fatal("holder with slice should not be deleted")
+	}
+	cache.Set("t4", holder{X: 1})
+	if !cache.CompareAndSwap("t4", holder{X: 1}, 2) {
+		t.Fatal("holder with comparable value should be swapped")
+	}
+	if v, _ := cache.Get("t1"); string(v.([]byte)) != "a" {
+		t.Fatal("t1 should be kept, got ", v)
+	}
+	if v, _ := cache.Get("t2"); v != "a" {
+		t.Fatal("t2 should be kept, got ", v)
+	}
+}
+
+func TestCacheTimer_Compute(t *testing.T) {
+	cache := NewCacheWithGC(2, 10, time.Minute)
+	defer cache.Close()
+	timer := cache.s.(*cacheTimer).expirer.(*wheelExpirer)
+
+	cache.GetOrSet("t1", 1, time.Hour)
+	cache.Compute("t2", func(interface{}, bool) (interface{}, bool) { return 2, true }, time.Hour)
+	cache.Update("t3", func(interface{}, bool) (interface{}, bool) { return 3, true })
+	if n := timer.Len(); n != 2 {
+		t.Fatal("timer should have 2 nodes, got ", n)
+	}
+
+	cache.CompareAndDelete("t1", 1)
+	cache.Compute("t2", func(interface{}, bool) (interface{}, bool) { return 2, true }, -1)
+	if n := timer.Len(); n != 0 {
+		t.Fatal("timer should be empty, got ", n)
+	}
+}
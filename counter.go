@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrNotInteger = errors.New("cache value is not an integer")
+	ErrNotNumeric = errors.New("cache value is not numeric")
+	ErrOverflow   = errors.New("cache increment or decrement would overflow")
+)
+
+// Incr 与IncrBy(key, 1, ttl)相同
+func (c *Cache) Incr(key string, ttl time.Duration) (int64, error) {
+	return c.IncrBy(key, 1, ttl)
+}
+
+// Decr 与DecrBy(key, 1, ttl)相同
+func (c *Cache) Decr(key string, ttl time.Duration) (int64, error) {
+	return c.DecrBy(key, 1, ttl)
+}
+
+// IncrBy 将key的整数value原子地加上delta并返回结果，保留value原来的类型及过期时间
+// key不存在时写入int64类型的delta，过期时间为ttl，ttl < 0 表示不过期，需要每次修改都重置过期时间时使用IncrByEx
+// value不是整数时返回ErrNotInteger，结果超出value类型或int64的范围时返回ErrOverflow，均不修改value
+// 原地修改value不回调RemovalReplaced
+func (c *Cache) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.incrBy(key, delta, ttl, false)
+}
+
+// IncrByEx 与IncrBy相同，同时在同一次修改中将key的过期时间重置为ttl，ttl < 0 表示不过期
+func (c *Cache) IncrByEx(key string, delta int64, ttl time.Duration) (int64, error) {
+	return c.incrBy(key, delta, ttl, true)
+}
+
+// incrBy reset为true时已有的key也按ttl写入过期时间
+func (c *Cache) incrBy(key string, delta int64, ttl time.Duration, reset bool) (int64, error) {
+	var (
+		result int64
+		err    error
+	)
+	expAt, meta := c.expiry(ttl)
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if !exists(item) {
+			result = delta
+			return delta, expAt, meta, computeSet
+		}
+		var value interface{}
+		value, result, err = addInteger(item.value, delta)
+		if err != nil {
+			err = fmt.Errorf("%w: key %s", err, key)
+			return nil, 0, entryMeta{}, computeNone
+		}
+		if reset {
+			return value, expAt, meta, computeUpdate
+		}
+		return value, item.expAt, item.meta, computeUpdate
+	})
+	return result, err
+}
+
+// DecrBy 与IncrBy(key, -delta, ttl)相同
+func (c *Cache) DecrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, fmt.Errorf("%w: key %s", ErrOverflow, key)
+	}
+	return c.IncrBy(key, -delta, ttl)
+}
+
+// IncrByFloat 将key的数值value原子地加上delta并返回结果，float32类型的value保留原来的类型，其它类型写入float64，保留过期时间
+// key不存在时写入float64类型的delta，过期时间为ttl，ttl < 0 表示不过期，需要每次修改都重置过期时间时使用IncrByFloatEx
+// value不是数值时返回ErrNotNumeric，结果为NaN或超出类型的范围时返回ErrOverflow，均不修改value
+// 原地修改value不回调RemovalReplaced
+func (c *Cache) IncrByFloat(key string, delta float64, ttl time.Duration) (float64, error) {
+	return c.incrByFloat(key, delta, ttl, false)
+}
+
+// IncrByFloatEx 与IncrByFloat相同，同时在同一次修改中将key的过期时间重置为ttl，ttl < 0 表示不过期
+func (c *Cache) IncrByFloatEx(key string, delta float64, ttl time.Duration) (float64, error) {
+	return c.incrByFloat(key, delta, ttl, true)
+}
+
+// incrByFloat reset为true时已有的key也按ttl写入过期时间
+func (c *Cache) incrByFloat(key string, delta float64, ttl time.Duration, reset bool) (float64, error) {
+	var (
+		result float64
+		err    error
+	)
+	expAt, meta := c.expiry(ttl)
+	c.s.Compute(c.s.Index(key), key, func(item *entry) (interface{}, int64, entryMeta, computeAction) {
+		if !exists(item) {
+			if math.IsNaN(delta) || math.IsInf(delta, 0) {
+				err = fmt.Errorf("%w: key %s", ErrOverflow, key)
+				return nil, 0, entryMeta{}, computeNone
+			}
+			result = delta
+			return delta, expAt, meta, computeSet
+		}
+		var value interface{}
+		value, result, err = addFloat(item.value, delta)
+		if err != nil {
+			err = fmt.Errorf("%w: key %s", err, key)
+			return nil, 0, entryMeta{}, computeNone
+		}
+		if reset {
+			return value, expAt, meta, computeUpdate
+		}
+		return value, item.expAt, item.meta, computeUpdate
+	})
+	return result, err
+}
+
+// addInteger 支持baseTypeValue中的所有整数类型
+func addInteger(value interface{}, delta int64) (interface{}, int64, error) {
+	switch v := value.(type) {
+	case int:
+		n, err := addSigned(int64(v), delta, math.MinInt, math.MaxInt)
+		return int(n), n, err
+	case int8:
+		n, err := addSigned(int64(v), delta, math.MinInt8, math.MaxInt8)
+		return int8(n), n, err
+	case int16:
+		n, err := addSigned(int64(v), delta, math.MinInt16, math.MaxInt16)
+		return int16(n), n, err
+	case int32:
+		n, err := addSigned(int64(v), delta, math.MinInt32, math.MaxInt32)
+		return int32(n), n, err
+	case int64:
+		n, err := addSigned(v, delta, math.MinInt64, math.MaxInt64)
+		return n, n, err
+	case uint:
+		n, err := addUnsigned(uint64(v), delta, math.MaxUint)
+		return uint(n), int64(n), err
+	case uint8:
+		n, err := addUnsigned(uint64(v), delta, math.MaxUint8)
+		return uint8(n), int64(n), err
+	case uint16:
+		n, err := addUnsigned(uint64(v), delta, math.MaxUint16)
+		return uint16(n), int64(n), err
+	case uint32:
+		n, err := addUnsigned(uint64(v), delta, math.MaxUint32)
+		return uint32(n), int64(n), err
+	case uint64:
+		n, err := addUnsigned(v, delta, math.MaxUint64)
+		return n, int64(n), err
+	default:
+		return nil, 0, ErrNotInteger
+	}
+}
+
+func addSigned(v, delta, min, max int64) (int64, error) {
+	if (delta > 0 && v > max-delta) || (delta < 0 && v < min-delta) {
+		return 0, ErrOverflow
+	}
+	return v + delta, nil
+}
+
+// addUnsigned 结果同时需要在int64的范围内
+func addUnsigned(v uint64, delta int64, max uint64) (uint64, error) {
+	if max > math.MaxInt64 {
+		max = math.MaxInt64
+	}
+	if delta >= 0 {
+		if v > max || uint64(delta) > max-v {
+			return 0, ErrOverflow
+		}
+		return v + uint64(delta), nil
+	}
+	abs := uint64(-(delta + 1)) + 1
+	if abs > v || v-abs > max {
+		return 0, ErrOverflow
+	}
+	return v - abs, nil
+}
+
+func addFloat(value interface{}, delta float64) (interface{}, float64, error) {
+	var f float64
+	switch v := value.(type) {
+	case float32:
+		n := float32(float64(v) + delta)
+		if math.IsNaN(float64(n)) || math.IsInf(float64(n), 0) {
+			return nil, 0, ErrOverflow
+		}
+		return n, float64(n), nil
+	case float64:
+		f = v
+	case int:
+		f = float64(v)
+	case int8:
+		f = float64(v)
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	default:
+		return nil, 0, ErrNotNumeric
+	}
+	n := f + delta
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, 0, ErrOverflow
+	}
+	return n, n, nil
+}
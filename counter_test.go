@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_IncrBy(t *testing.T) {
+	cache := NewCache(2, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.IncrBy("t1", 2, time.Hour); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("t1"); v != int64(200) {
+		t.Fatal("t1 should be 200, got ", v)
+	}
+	if n, _ := cache.DecrBy("t1", 50, -1); n != 150 {
+		t.Fatal("t1 should be 150, got ", n)
+	}
+	// 保留原来的过期时间
+	if ttl, _ := cache.TTL("t1"); ttl <= 59*time.Minute {
+		t.Fatal("t1 ttl should be kept, got ", ttl)
+	}
+
+	if n, _ := cache.Decr("t2", -1); n != -1 {
+		t.Fatal("t2 should be -1, got ", n)
+	}
+	if ttl, _ := cache.TTL("t2"); ttl != NoExpiration {
+		t.Fatal("t2 should not expire, got ", ttl)
+	}
+}
+
+func TestCache_IncrByEx(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	r := newRemovalRecorder()
+	cache := NewCache(2, 10, WithClock(clock), WithRemovalListener(r.listen))
+	start := clock.Now()
+
+	cache.IncrBy("t1", 1, time.Minute)
+	cache.IncrByFloat("t2", 1.5, time.Minute)
+	clock.Set(start.Add(30 * time.Second))
+
+	// IncrBy保留过期时间，IncrByEx在同一次修改中重置过期时间
+	if n, _ := cache.IncrBy("t1", 1, time.Hour); n != 2 {
+		t.Fatal("t1 should be 2, got ", n)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl != 30*time.Second {
+		t.Fatal("t1 ttl should be kept, got ", ttl)
+	}
+	if n, _ := cache.IncrByEx("t1", 1, time.Minute); n != 3 {
+		t.Fatal("t1 should be 3, got ", n)
+	}
+	if ttl, _ := cache.TTL("t1"); ttl != time.Minute {
+		t.Fatal("t1 ttl should be reset, got ", ttl)
+	}
+	if f, _ := cache.IncrByFloatEx("t2", 1, -1); f != 2.5 {
+		t.Fatal("t2 should be 2.5, got ", f)
+	}
+	if ttl, _ := cache.TTL("t2"); ttl != NoExpiration {
+		t.Fatal("t2 should not expire, got ", ttl)
+	}
+
+	// 原地修改value不回调RemovalReplaced
+	if r.reason("t1") != 0 || r.reason("t2") != 0 {
+		t.Fatal("increment should not notify replaced")
+	}
+	cache.Set("t1", 0)
+	if r.reason("t1") != RemovalReplaced {
+		t.Fatal("t1 should be replaced by Set")
+	}
+}
+
+func TestCache_IncrBy_Types(t *testing.T) {
+	cache := NewCache(2, 10)
+	values := map[string]interface{}{
+		"int":    int(1),
+		"int8":   int8(1),
+		"int16":  int16(1),
+		"int32":  int32(1),
+		"int64":  int64(1),
+		"uint":   uint(1),
+		"uint8":  uint8(1),
+		"uint16": uint16(1),
+		"uint32": uint32(1),
+		"uint64": uint64(1),
+	}
+	for key, value := range values {
+		cache.Set(key, value)
+		if n, err := cache.Incr(key, -1); err != nil || n != 2 {
+			t.Fatalf("%s should be 2, got %d %v", key, n, err)
+		}
+		// 保留value原来的类型
+		if v, _ := cache.Get(key); reflect.TypeOf(v) != reflect.TypeOf(value) {
+			t.Fatalf("%s type changed: %T", key, v)
+		}
+	}
+
+	cache.Set("str", "1")
+	if _, err := cache.Incr("str", -1); !errors.Is(err, ErrNotInteger) {
+		t.Fatal("str should not be an integer, got ", err)
+	}
+	cache.Set("float", 1.5)
+	if _, err := cache.Incr("float", -1); !errors.Is(err, ErrNotInteger) {
+		t.Fatal("float should not be an integer, got ", err)
+	}
+
+	overflows := []struct {
+		value interface{}
+		delta int64
+	}{
+		{int8(math.MaxInt8), 1},
+		{int16(math.MinInt16), -1},
+		{int64(math.MaxInt64), 1},
+		{uint8(0), -1},
+		{uint32(math.MaxUint32), 1},
+		{uint64(math.MaxUint64), -1},
+	}
+	for _, o := range overflows {
+		cache.Set("overflow", o.value)
+		if _, err := cache.IncrBy("overflow", o.delta, -1); !errors.Is(err, ErrOverflow) {
+			t.Fatalf("%T %v + %d should overflow, got %v", o.value, o.value, o.delta, err)
+		}
+		if v, _ := cache.Get("overflow"); v != o.value {
+			t.Fatal("overflow value should not change, got ", v)
+		}
+	}
+	if _, err := cache.DecrBy("t1", math.MinInt64, -1); !errors.Is(err, ErrOverflow) {
+		t.Fatal("decr by MinInt64 should overflow")
+	}
+}
+
+func TestCache_IncrByFloat(t *testing.T) {
+	cache := NewCache(2, 10)
+	if n, _ := cache.IncrByFloat("t1", 1.5, time.Hour); n != 1.5 {
+		t.Fatal("t1 should be 1.5, got ", n)
+	}
+	if n, _ := cache.IncrByFloat("t1", 1, -1); n != 2.5 {
+		t.Fatal("t1 should be 2.5, got ", n)
+	}
+
+	cache.Set("t2", float32(1))
+	if n, _ := cache.IncrByFloat("t2", 0.5, -1); n != 1.5 {
+		t.Fatal("t2 should be 1.5, got ", n)
+	}
+	if v, _ := cache.Get("t2"); v != float32(1.5) {
+		t.Fatal("t2 should keep float32, got ", v)
+	}
+
+	cache.Set("t3", uint16(1))
+	if n, _ := cache.IncrByFloat("t3", 0.5, -1); n != 1.5 {
+		t.Fatal("t3 should be 1.5, got ", n)
+	}
+
+	cache.Set("t4", "1")
+	if _, err := cache.IncrByFloat("t4", 1, -1); !errors.Is(err, ErrNotNumeric) {
+		t.Fatal("t4 should not be numeric, got ", err)
+	}
+	if _, err := cache.IncrByFloat("t1", math.Inf(1), -1); !errors.Is(err, ErrOverflow) {
+		t.Fatal("t1 + inf should overflow, got ", err)
+	}
+}
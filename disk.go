@@ -247,6 +247,27 @@ func baseTypeValue(value interface{}) (byte, []byte) {
 	return id, data
 }
 
+// baseTypeSize 基础数据类型value的字节数，与baseTypeValue编码后的长度一致
+// 其它类型返回interface大小
+func baseTypeSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case int, int64, uint64, uint, float64:
+		return 8
+	case int32, uint32, float32:
+		return 4
+	case int16, uint16:
+		return 2
+	case int8, uint8, bool:
+		return 1
+	default:
+		return 16
+	}
+}
+
 var Discard io.Writer = discard{}
 
 type discard struct{}
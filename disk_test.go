@@ -46,3 +46,12 @@ func TestKvItem_ResolveKvFromReader(t *testing.T) {
 		t.Fatal("load kvs less")
 	}
 }
+
+func TestBaseTypeSize(t *testing.T) {
+	for k, v := range _kvs {
+		_, data := baseTypeValue(v)
+		if size := baseTypeSize(v); size != int64(len(data)) {
+			t.Fatalf("%s size should be %d, got %d", k, len(data), size)
+		}
+	}
+}
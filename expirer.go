@@ -0,0 +1,445 @@
+package cache
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	_defSampleSize       = 20 // 抽样清理时每个分片每次检查的key数量
+	_sampleBudgetPercent = 25 // 每个tick抽样清理的耗时上限占tick的百分比
+)
+
+// ExpiryStrategy NewCacheWithGC主动清理过期key的方式
+type ExpiryStrategy int
+
+const (
+	// ExpireByTimingWheel 分层时间轮，每个gcInterval推进一次，默认方式
+	ExpireByTimingWheel ExpiryStrategy = iota
+	// ExpireByHeap 每个分片一个按过期时间排序的最小堆，通过Clock.AfterFunc休眠到最早的过期时间，gcInterval不生效，
+	// 没有到期的key时不唤醒，适合带过期时间的key较少但ttl差异较大的场景
+	ExpireByHeap
+	// ExpireBySampling 每个gcInterval从每个分片带过期时间的key中随机抽样检查过期key，抽样中过期的key超过25%时继续抽样，
+	// 每个gcInterval的耗时不超过其25%，只记录带过期时间的key而不记录过期时间，适合key数量很大的场景
+	ExpireBySampling
+)
+
+// expirer 主动清理过期key的方式，Run在单独的goroutine中执行直到stop关闭
+type expirer interface {
+	Add(index uint32, key string, expAt int64)
+	Remove(index uint32, key string)
+	Run(stop <-chan struct{})
+	// Release 调用前需保证Run已经退出
+	Release()
+}
+
+var (
+	_ expirer = (*wheelExpirer)(nil)
+	_ expirer = (*heapExpirer)(nil)
+	_ expirer = (*sampleExpirer)(nil)
+)
+
+// wheelExpirer 每个分片一个时间轮，分片之间不共享锁，由同一个ticker推进
+type wheelExpirer struct {
+	timers []*timer
+	ticker Ticker
+}
+
+func newWheelExpirer(sharedNum int, tick time.Duration, clock Clock, handle func(index uint32, now int64, keys []string)) *wheelExpirer {
+	now := clock.Now().UnixNano()
+	timers := make([]*timer, sharedNum)
+	for i := range timers {
+		index := uint32(i)
+		timers[i] = newTimer(tick, now, func(now int64, keys []string) {
+			handle(index, now, keys)
+		})
+	}
+	return &wheelExpirer{
+		timers: timers,
+		ticker: clock.NewTicker(tick),
+	}
+}
+
+func (w *wheelExpirer) Add(index uint32, key string, expAt int64) {
+	w.timers[index].Add(key, expAt)
+}
+
+func (w *wheelExpirer) Remove(index uint32, key string) {
+	w.timers[index].Remove(key)
+}
+
+func (w *wheelExpirer) Run(stop <-chan struct{}) {
+	defer w.ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-w.ticker.C():
+			for _, t := range w.timers {
+				t.advanceClock(now.UnixNano())
+			}
+		}
+	}
+}
+
+func (w *wheelExpirer) Release() {
+	for _, t := range w.timers {
+		t.Release()
+	}
+}
+
+// Len 所有时间轮中的节点数
+func (w *wheelExpirer) Len() int {
+	var n int
+	for _, t := range w.timers {
+		n += t.Len()
+	}
+	return n
+}
+
+// heapExpirer 只保留一个定时器，加入更早的过期时间时重新设置，定时器到期时删除所有分片中到期的key
+type heapExpirer struct {
+	heaps  []*expHeap
+	clock  Clock
+	handle func(index uint32, now int64, keys []string)
+
+	mu       sync.Mutex
+	timer    Timer
+	deadline int64  // timer的到期时间，math.MaxInt64 表示没有timer
+	gen      uint64 // 每次设置timer时加1，忽略已被替换的timer
+	stopped  bool
+}
+
+func newHeapExpirer(sharedNum int, clock Clock, handle func(index uint32, now int64, keys []string)) *heapExpirer {
+	heaps := make([]*expHeap, sharedNum)
+	for i := range heaps {
+		heaps[i] = &expHeap{index: make(map[string]*heapNode)}
+	}
+	return &heapExpirer{
+		heaps:    heaps,
+		clock:    clock,
+		handle:   handle,
+		deadline: math.MaxInt64,
+	}
+}
+
+// Add 早于timer的到期时间时重新设置timer
+func (e *heapExpirer) Add(index uint32, key string, expAt int64) {
+	e.heaps[index].Set(key, expAt)
+	e.arm(expAt)
+}
+
+func (e *heapExpirer) Remove(index uint32, key string) {
+	e.heaps[index].Remove(key)
+}
+
+// Run 清理由timer执行，stop关闭后停止timer
+func (e *heapExpirer) Run(stop <-chan struct{}) {
+	<-stop
+	e.mu.Lock()
+	e.stopped = true
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+}
+
+// arm 设置在deadline到期的timer，已有更早的timer时不修改
+func (e *heapExpirer) arm(deadline int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped || deadline >= e.deadline {
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.gen++
+	gen := e.gen
+	e.deadline = deadline
+	e.timer = e.clock.AfterFunc(time.Duration(deadline-e.clock.Now().UnixNano()), func() {
+		e.fire(gen, deadline)
+	})
+}
+
+// fire 按timer的到期时间删除到期的key，时钟落后时也不会提前唤醒，再按剩余最早的过期时间设置timer
+func (e *heapExpirer) fire(gen uint64, deadline int64) {
+	e.mu.Lock()
+	if e.stopped || gen != e.gen {
+		e.mu.Unlock()
+		return
+	}
+	e.timer = nil
+	e.deadline = math.MaxInt64
+	e.mu.Unlock()
+
+	now := e.clock.Now().UnixNano()
+	if now < deadline {
+		now = deadline
+	}
+	if next := e.expire(now); next != math.MaxInt64 {
+		e.arm(next)
+	}
+}
+
+// expire 删除所有分片中已过期的key，返回剩余最早的过期时间
+func (e *heapExpirer) expire(now int64) int64 {
+	next := int64(math.MaxInt64)
+	for i, h := range e.heaps {
+		keys, top := h.PopBefore(now)
+		if len(keys) > 0 {
+			e.handle(uint32(i), now, keys)
+		}
+		if top < next {
+			next = top
+		}
+	}
+	return next
+}
+
+func (e *heapExpirer) Release() {
+	for _, h := range e.heaps {
+		h.Release()
+	}
+}
+
+// expHeap 按过期时间排序的最小堆，每个key最多一个节点
+type expHeap struct {
+	mu       sync.Mutex
+	released bool
+	nodes    heapNodes
+	index    map[string]*heapNode
+}
+
+type heapNode struct {
+	key   string
+	expAt int64
+	pos   int
+}
+
+func (h *expHeap) Set(key string, expAt int64) {
+	h.mu.Lock()
+	if h.released {
+		h.mu.Unlock()
+		return
+	}
+	if n, ok := h.index[key]; ok {
+		n.expAt = expAt
+		heap.Fix(&h.nodes, n.pos)
+	} else {
+		n = &heapNode{key: key, expAt: expAt}
+		h.index[key] = n
+		heap.Push(&h.nodes, n)
+	}
+	h.mu.Unlock()
+}
+
+func (h *expHeap) Remove(key string) {
+	h.mu.Lock()
+	if n, ok := h.index[key]; ok {
+		heap.Remove(&h.nodes, n.pos)
+		delete(h.index, key)
+	}
+	h.mu.Unlock()
+}
+
+// PopBefore 取出过期时间不晚于now的key，并返回剩余最早的过期时间
+func (h *expHeap) PopBefore(now int64) ([]string, int64) {
+	var keys []string
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for len(h.nodes) > 0 && h.nodes[0].expAt <= now {
+		n := heap.Pop(&h.nodes).(*heapNode)
+		delete(h.index, n.key)
+		keys = append(keys, n.key)
+	}
+	if len(h.nodes) == 0 {
+		return keys, math.MaxInt64
+	}
+	return keys, h.nodes[0].expAt
+}
+
+func (h *expHeap) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.nodes)
+}
+
+func (h *expHeap) Release() {
+	h.mu.Lock()
+	h.released = true
+	h.nodes = nil
+	h.index = nil
+	h.mu.Unlock()
+}
+
+type heapNodes []*heapNode
+
+func (h heapNodes) Len() int { return len(h) }
+
+func (h heapNodes) Less(i, j int) bool { return h[i].expAt < h[j].expAt }
+
+func (h heapNodes) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *heapNodes) Push(x interface{}) {
+	n := x.(*heapNode)
+	n.pos = len(*h)
+	*h = append(*h, n)
+}
+
+func (h *heapNodes) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return n
+}
+
+// sampleExpirer 类似redis的主动过期，每个tick从每个分片随机抽取带过期时间的key，删除其中已过期的key，
+// 过期比例超过25%时立即重复抽样，超出耗时上限时停止，下个tick从未处理完的分片继续
+// Add及Remove在分片的写锁内调用，keySet由分片的写锁保护
+type sampleExpirer struct {
+	sharers []*shared
+	sets    []*keySet
+	ticker  Ticker
+	clock   Clock
+	samples int
+	budget  time.Duration
+	next    int
+}
+
+func newSampleExpirer(sharers []*shared, tick time.Duration, clock Clock) *sampleExpirer {
+	sets := make([]*keySet, len(sharers))
+	for i := range sets {
+		sets[i] = newKeySet()
+	}
+	return &sampleExpirer{
+		sharers: sharers,
+		sets:    sets,
+		ticker:  clock.NewTicker(tick),
+		clock:   clock,
+		samples: _defSampleSize,
+		budget:  tick * _sampleBudgetPercent / 100,
+	}
+}
+
+func (e *sampleExpirer) Add(index uint32, key string, _ int64) {
+	e.sets[index].Add(key)
+}
+
+func (e *sampleExpirer) Remove(index uint32, key string) {
+	e.sets[index].Remove(key)
+}
+
+// Run 不晚于上一次的tick不处理
+func (e *sampleExpirer) Run(stop <-chan struct{}) {
+	defer e.ticker.Stop()
+
+	var last int64
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-e.ticker.C():
+			if tick := now.UnixNano(); tick > last {
+				last = tick
+				e.expire()
+			}
+		}
+	}
+}
+
+// expire 耗时按系统时间计算
+func (e *sampleExpirer) expire() {
+	start := time.Now()
+	n := len(e.sharers)
+	for i := 0; i < n; i++ {
+		index := (e.next + i) % n
+		s := e.sharers[index]
+		for {
+			sampled, expired := s.SampleExpired(e.sets[index], e.samples, e.clock.Now().UnixNano())
+			if time.Since(start) >= e.budget {
+				e.next = index
+				return
+			}
+			if sampled == 0 || expired*4 <= sampled {
+				break
+			}
+		}
+	}
+}
+
+// Release keySet随分片中的key一起释放，关闭后写入的key仍会记录
+func (e *sampleExpirer) Release() {}
+
+// keySet 支持随机抽样的key集合，不是并发安全的
+type keySet struct {
+	keys   []string
+	pos    map[string]int
+	sample []string
+	seed   uint64
+}
+
+func newKeySet() *keySet {
+	return &keySet{
+		pos:  make(map[string]int),
+		seed: uint64(time.Now().UnixNano()) | 1,
+	}
+}
+
+func (k *keySet) Add(key string) {
+	if _, ok := k.pos[key]; ok {
+		return
+	}
+	k.pos[key] = len(k.keys)
+	k.keys = append(k.keys, key)
+}
+
+// Remove 将最后一个key移动到被删除的位置
+func (k *keySet) Remove(key string) {
+	i, ok := k.pos[key]
+	if !ok {
+		return
+	}
+	last := len(k.keys) - 1
+	k.keys[i] = k.keys[last]
+	k.pos[k.keys[i]] = i
+	k.keys[last] = _EMPTY_STR
+	k.keys = k.keys[:last]
+	delete(k.pos, key)
+}
+
+func (k *keySet) Len() int {
+	return len(k.keys)
+}
+
+// Sample 不重复地随机抽取最多n个key，返回的切片在下次调用Sample前有效
+func (k *keySet) Sample(n int) []string {
+	if n > len(k.keys) {
+		n = len(k.keys)
+	}
+	k.sample = k.sample[:0]
+	for i := 0; i < n; i++ {
+		j := i + k.rand(len(k.keys)-i)
+		k.keys[i], k.keys[j] = k.keys[j], k.keys[i]
+		k.pos[k.keys[i]], k.pos[k.keys[j]] = i, j
+		k.sample = append(k.sample, k.keys[i])
+	}
+	return k.sample
+}
+
+// rand xorshift64，返回[0, n)
+func (k *keySet) rand(n int) int {
+	k.seed ^= k.seed << 13
+	k.seed ^= k.seed >> 7
+	k.seed ^= k.seed << 17
+	return int(k.seed % uint64(n))
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _strategies = []struct {
+	name     string
+	strategy ExpiryStrategy
+}{
+	{"timingWheel", ExpireByTimingWheel},
+	{"heap", ExpireByHeap},
+	{"sampling", ExpireBySampling},
+}
+
+func TestExpiryStrategy(t *testing.T) {
+	for _, st := range _strategies {
+		t.Run(st.name, func(t *testing.T) {
+			var mu sync.Mutex
+			expired := make(map[string]bool)
+			c := NewCacheWithGC(2, 10, 5*time.Millisecond, WithExpiryStrategy(st.strategy),
+				WithRemovalListener(func(key string, value interface{}, reason RemovalReason) {
+					if reason == RemovalExpired {
+						mu.Lock()
+						expired[key] = true
+						mu.Unlock()
+					}
+				}))
+			defer c.Close()
+
+			// 先写入较晚过期的key，堆顶随之后写入的key更新
+			c.SetEx("late", 1, time.Hour)
+			for i := 0; i < 10; i++ {
+				c.SetEx(fmt.Sprintf("t%d", i), i, time.Duration(10+i*2)*time.Millisecond)
+			}
+			c.Set("forever", 1)
+			c.SetEx("persist", 1, 10*time.Millisecond)
+			c.Set("persist", 2)
+
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				mu.Lock()
+				n := len(expired)
+				mu.Unlock()
+				if n >= 10 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("expired keys should be cleaned, got ", n)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			for _, key := range []string{"late", "forever", "persist"} {
+				if _, err := c.Get(key); err != nil {
+					t.Fatalf("%s should exist", key)
+				}
+			}
+		})
+	}
+}
+
+func TestExpHeap(t *testing.T) {
+	h := &expHeap{index: make(map[string]*heapNode)}
+	h.Set("t1", 30)
+	h.Set("t2", 10)
+	h.Set("t3", 20)
+	h.Set("t4", 40)
+	// 重复写入只保留一个节点
+	h.Set("t1", 5)
+	h.Remove("t3")
+	h.Remove("t5")
+	if h.Len() != 3 {
+		t.Fatal("heap should have 3 nodes, got ", h.Len())
+	}
+
+	keys, next := h.PopBefore(10)
+	if len(keys) != 2 || keys[0] != "t1" || keys[1] != "t2" {
+		t.Fatal("pop keys: ", keys)
+	}
+	if next != 40 {
+		t.Fatal("next expAt should be 40, got ", next)
+	}
+
+	h.Release()
+	h.Set("t6", 1)
+	if h.Len() != 0 {
+		t.Fatal("released heap should be empty")
+	}
+}
+
+// sampleSharers 分片通过schedule将带过期时间的key记录到e中
+func sampleSharers(e *sampleExpirer) {
+	for i, s := range e.sharers {
+		index := uint32(i)
+		s.schedule = func(key string, expAt int64) {
+			if expAt >= 0 {
+				e.Add(index, key, expAt)
+			} else {
+				e.Remove(index, key)
+			}
+		}
+	}
+}
+
+func TestShared_SampleExpired(t *testing.T) {
+	s := newShared(10)
+	e := newSampleExpirer([]*shared{s}, time.Second, _realClock)
+	defer e.ticker.Stop()
+	sampleSharers(e)
+	for i := 0; i < 10; i++ {
+		s.Set(fmt.Sprintf("t%d", i), i, int64(i))
+	}
+	s.Set("forever", 1, -1)
+	if n := e.sets[0].Len(); n != 10 {
+		t.Fatal("should record 10 keys with ttl, got ", n)
+	}
+
+	sampled, expired := s.SampleExpired(e.sets[0], 20, 4)
+	if sampled != 10 || expired != 5 {
+		t.Fatalf("sampled %d expired %d", sampled, expired)
+	}
+	if n := e.sets[0].Len(); n != 5 {
+		t.Fatal("expired keys should be removed from set, got ", n)
+	}
+	if sampled, _ = s.SampleExpired(e.sets[0], 3, 4); sampled != 3 {
+		t.Fatal("should sample 3 keys, got ", sampled)
+	}
+
+	// 不过期的key不参与抽样
+	for i := 5; i < 10; i++ {
+		s.Set(fmt.Sprintf("t%d", i), i, -1)
+	}
+	if sampled, _ = s.SampleExpired(e.sets[0], 20, 4); sampled != 0 {
+		t.Fatal("persistent keys should not be sampled, got ", sampled)
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	k := newKeySet()
+	for i := 0; i < 100; i++ {
+		k.Add(fmt.Sprintf("t%d", i))
+	}
+	k.Add("t1")
+	for i := 0; i < 100; i += 2 {
+		k.Remove(fmt.Sprintf("t%d", i))
+	}
+	if k.Len() != 50 {
+		t.Fatal("set should have 50 keys, got ", k.Len())
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range k.Sample(30) {
+		if seen[key] {
+			t.Fatal("sample should not repeat ", key)
+		}
+		seen[key] = true
+		if _, ok := k.pos[key]; !ok {
+			t.Fatal("sampled key should be in set ", key)
+		}
+	}
+	if len(seen) != 30 {
+		t.Fatal("should sample 30 keys, got ", len(seen))
+	}
+	for key, i := range k.pos {
+		if k.keys[i] != key {
+			t.Fatal("position of ", key, " is broken")
+		}
+	}
+}
+
+func TestSampleExpirer_Adaptive(t *testing.T) {
+	sharers := []*shared{newShared(10), newShared(10)}
+	e := newSampleExpirer(sharers, time.Second, _realClock)
+	defer e.ticker.Stop()
+	sampleSharers(e)
+	for i, s := range sharers {
+		for j := 0; j < 1000; j++ {
+			s.Set(fmt.Sprintf("t%d-%d", i, j), j, 1)
+		}
+		for j := 0; j < 10; j++ {
+			s.Set(fmt.Sprintf("live%d-%d", i, j), j, time.Now().Add(time.Hour).UnixNano())
+		}
+	}
+
+	// 过期比例超过25%时重复抽样，只剩少量过期key
+	e.budget = time.Hour
+	e.expire()
+	for i, s := range sharers {
+		if n := len(s.entries); n < 10 || n > 20 {
+			t.Fatalf("shared %d should have about 10 keys, got %d", i, n)
+		}
+	}
+
+	for j := 0; j < 1000; j++ {
+		sharers[1].Set(fmt.Sprintf("t1-%d", j), j, 1)
+	}
+	// 超出耗时上限时停止，下次从未处理完的分片继续
+	before := len(sharers[1].entries)
+	e.next = 1
+	e.budget = 0
+	e.expire()
+	if n := len(sharers[1].entries); before-n > _defSampleSize {
+		t.Fatal("should sample once when out of budget, got ", n)
+	}
+	if e.next != 1 {
+		t.Fatal("next shared should be 1, got ", e.next)
+	}
+}
@@ -0,0 +1,52 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/welllog/cache"
+	"github.com/welllog/cache/cachetest"
+)
+
+func TestCache_RefreshFailureKeepsValue(t *testing.T) {
+	clock := cachetest.NewFakeClock(time.Unix(1000, 0))
+	refreshErr := make(chan error, 1)
+	c := cache.NewCache(2, 10, cache.WithClock(clock), cache.WithNegativeCache(time.Minute, nil),
+		cache.WithRefreshErrorHandler(func(key string, err error) {
+			refreshErr <- err
+		}))
+	failed := func() (interface{}, error) {
+		return nil, cache.ErrNil
+	}
+	waitErr := func() {
+		select {
+		case err := <-refreshErr:
+			if !cache.ErrIsNotFound(err) {
+				t.Fatal("refresh error should be ErrNil, got ", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("refresh error should be reported")
+		}
+	}
+
+	// 提前刷新失败时保留未过期的value
+	_, _ = c.LoadWithEx("t1", func() (interface{}, error) { return 1, nil }, 10*time.Second)
+	clock.Advance(6 * time.Second)
+	if v, err := c.LoadWithEx("t1", failed, 10*time.Second, cache.WithRefreshAhead(0.5)); err != nil || v != 1 {
+		t.Fatal("old value should be returned, got ", v, err)
+	}
+	waitErr()
+	if v, err := c.Get("t1"); err != nil || v != 1 {
+		t.Fatal("old value should be kept after refresh failure, got ", v, err)
+	}
+
+	// 异步加载过期value失败时仍返回旧value
+	clock.Advance(5 * time.Second)
+	for i := 0; i < 2; i++ {
+		v, err := c.LoadAsyncWithStale("t1", failed, 10*time.Second, time.Minute)
+		if err != nil || v != 1 {
+			t.Fatal("stale value should be returned, got ", v, err)
+		}
+		waitErr()
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitter 为ttl增加[0, span]的随机时长，避免同时写入的key在同一时刻过期
+type jitter struct {
+	ratio float64
+	max   time.Duration
+	mu    sync.Mutex
+	rand  *rand.Rand
+}
+
+func newJitter(ratio float64, max time.Duration, seed int64, seeded bool) *jitter {
+	if ratio <= 0 && max <= 0 {
+		return nil
+	}
+	j := &jitter{
+		ratio: ratio,
+		max:   max,
+	}
+	if seeded {
+		j.rand = rand.New(rand.NewSource(seed))
+	}
+	return j
+}
+
+// Apply 比例与绝对范围同时设置时取较大的范围
+func (j *jitter) Apply(ttl time.Duration) time.Duration {
+	if j == nil || ttl <= 0 {
+		return ttl
+	}
+	span := int64(float64(ttl) * j.ratio)
+	if int64(j.max) > span {
+		span = int64(j.max)
+	}
+	if span <= 0 {
+		return ttl
+	}
+
+	var n int64
+	if j.rand == nil {
+		n = rand.Int63n(span + 1)
+	} else {
+		j.mu.Lock()
+		n = j.rand.Int63n(span + 1)
+		j.mu.Unlock()
+	}
+	return ttl + time.Duration(n)
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestJitter_Apply(t *testing.T) {
+	var j *jitter
+	if j.Apply(time.Second) != time.Second {
+		t.Fatal("nil jitter should not change ttl")
+	}
+
+	j = newJitter(0.1, 50*time.Millisecond, 1, true)
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		ttl := j.Apply(time.Second)
+		if ttl < time.Second || ttl > time.Second+100*time.Millisecond {
+			t.Fatal("ttl out of jitter range: ", ttl)
+		}
+		seen[ttl] = true
+	}
+	if len(seen) < 50 {
+		t.Fatal("ttl should be spread, distinct: ", len(seen))
+	}
+	if j.Apply(-1) != -1 {
+		t.Fatal("ttl without expiration should not change")
+	}
+}
+
+func TestCache_TTLJitter(t *testing.T) {
+	start := time.Unix(1000, 0)
+	expAts := func() map[string]int64 {
+		cache := NewCache(2, 100, WithClock(newManualClock(start)), WithTTLJitterRange(time.Minute), WithJitterSeed(42))
+		for i := 0; i < 100; i++ {
+			cache.SetEx(strconv.Itoa(i), i, time.Hour)
+		}
+		res := make(map[string]int64, 100)
+		cache.Scan(func(key string, value interface{}, expAt int64) {
+			res[key] = expAt
+		})
+		return res
+	}
+
+	first := expAts()
+	second := expAts()
+	min, max := start.Add(time.Hour).UnixNano(), start.Add(time.Hour+time.Minute).UnixNano()
+
+	// 相同种子相同写入顺序得到的延长时间相同
+	for key, expAt := range first {
+		if expAt < min || expAt > max {
+			t.Fatal("expAt out of jitter range, key: ", key)
+		}
+		if second[key] != expAt {
+			t.Fatal("jitter should be deterministic, key: ", key)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package cache
+
+// negativeCost 缓存的加载错误除key以外的固定成本
+const negativeCost = 16
+
+// negativeValue 缓存的加载错误
+type negativeValue struct {
+	err error
+}
+
+// negativeErr value为缓存的加载错误时返回该错误
+func negativeErr(value interface{}) (error, bool) {
+	if nv, ok := value.(*negativeValue); ok {
+		return nv.err, true
+	}
+	return nil, false
+}
+
+// storeErr 开启负缓存且err满足条件时缓存err
+func (c *Cache) storeErr(i uint32, key string, err error) {
+	if c.negativeTTL <= 0 || !c.negativeMatch(err) {
+		return
+	}
+	c.s.SetEx(i, key, &negativeValue{err: err}, c.clock.Now().UnixNano()+int64(c.jitter.Apply(c.negativeTTL)))
+}
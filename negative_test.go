@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCache_NegativeCache(t *testing.T) {
+	cache := NewCache(2, 10, WithNegativeCache(10*time.Millisecond, nil))
+
+	var calls int
+	notFound := func() (interface{}, error) {
+		calls++
+		return nil, fmt.Errorf("row missing: %w", ErrNil)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Load("t1", notFound); !ErrIsNotFound(err) {
+			t.Fatal("load should return not found, got ", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatal("loader should be called once, got ", calls)
+	}
+	if _, err := cache.Get("t1"); err == nil || err == ErrNil || !ErrIsNotFound(err) {
+		t.Fatal("get should return the cached error, got ", err)
+	}
+	cache.Scan(func(key string, value interface{}, expAt int64) {
+		t.Fatal("cached error should be skipped by scan")
+	})
+
+	// 负缓存过期后重新加载
+	time.Sleep(15 * time.Millisecond)
+	val, err := cache.Load("t1", func() (interface{}, error) {
+		return 1, nil
+	})
+	if err != nil || val != 1 {
+		t.Fatal("t1 should be reloaded")
+	}
+
+	// 不满足条件的错误不缓存
+	calls = 0
+	failed := func() (interface{}, error) {
+		calls++
+		return nil, errors.New("db down")
+	}
+	_, _ = cache.LoadWithEx("t2", failed, time.Second)
+	_, _ = cache.LoadWithEx("t2", failed, time.Second)
+	if calls != 2 {
+		t.Fatal("unmatched error should not be cached")
+	}
+}
+
+func TestCache_NegativeCacheMatch(t *testing.T) {
+	errDown := errors.New("db down")
+	cache := NewCache(2, 10, WithNegativeCache(time.Minute, func(err error) bool {
+		return errors.Is(err, errDown)
+	}))
+
+	_, err := cache.LoadCtx(context.Background(), "t1", func(ctx context.Context) (interface{}, error) {
+		return nil, errDown
+	}, time.Minute)
+	if !errors.Is(err, errDown) {
+		t.Fatal("load should return errDown, got ", err)
+	}
+	_, err = cache.LoadAsyncWithEx("t1", func() (interface{}, error) {
+		return 1, nil
+	}, time.Minute)
+	if !errors.Is(err, errDown) {
+		t.Fatal("cached error should be returned, got ", err)
+	}
+
+	// 写入新value覆盖缓存的错误
+	cache.Set("t1", 2)
+	if val, err := cache.Get("t1"); err != nil || val != 2 {
+		t.Fatal("t1 should be 2")
+	}
+}
+
+func TestCache_NegativeCacheRemoval(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	r := newRemovalRecorder()
+	var sized []interface{}
+	cache := NewCache(1, 0, WithClock(clock), WithRemovalListener(r.listen), WithNegativeCache(time.Minute, nil),
+		WithMaxCost(100), WithSizer(func(key string, value interface{}) int64 {
+			sized = append(sized, value)
+			return 30
+		}))
+	notFound := func() (interface{}, error) {
+		return nil, ErrNil
+	}
+
+	// 缓存的加载错误被覆盖、删除、过期及淘汰时都不回调，也不调用Sizer
+	_, _ = cache.Load("t1", notFound)
+	cache.Set("t1", 1)
+	_, _ = cache.Load("t2", notFound)
+	cache.Del("t2")
+	_, _ = cache.Load("t3", notFound)
+	clock.Set(clock.Now().Add(2 * time.Minute))
+	_, _ = cache.Get("t3")
+	_, _ = cache.Load("t4", notFound)
+	for _, key := range []string{"t5", "t6", "t7", "t8"} {
+		cache.Set(key, key)
+	}
+
+	r.mu.Lock()
+	for key, value := range r.values {
+		if _, negative := negativeErr(value); negative {
+			t.Fatalf("cached error of %s should not be notified", key)
+		}
+	}
+	r.mu.Unlock()
+	for _, value := range sized {
+		if _, negative := negativeErr(value); negative {
+			t.Fatal("cached error should not be sized")
+		}
+	}
+	if len(sized) != 5 {
+		t.Fatal("sizer should be called for values only, got ", len(sized))
+	}
+}
@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+type options struct {
+	maxEntries int
+	maxCost    int64
+	policy     PolicyFactory
+	sizer      Sizer
+	listener   RemovalListener
+	asyncSize  int
+
+	loadTimeout   time.Duration
+	negativeTTL   time.Duration
+	negativeMatch func(err error) bool
+	onRefreshErr  func(key string, err error)
+
+	jitterRatio  float64
+	jitterMax    time.Duration
+	jitterSeed   int64
+	jitterSeeded bool
+
+	expiry ExpiryStrategy
+
+	clock           Clock
+	coarsePrecision time.Duration
+	ownedClock      *CoarseClock // 由WithCoarseClock创建，随缓存关闭
+}
+
+type Option func(*options)
+
+// WithMaxEntries 限制缓存的最大key数量，平均分配到每个分片（向下取整），超出时按淘汰策略淘汰，默认LRU
+// 每个分片至少保留1个key，n小于分片数时实际上限为分片数，n <= 0 表示不限制
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxCost 限制缓存的最大总成本，平均分配到每个分片，超出时按淘汰策略淘汰直到总成本不超过限制
+// 未指定成本的写入由Sizer计算成本，n <= 0 表示不限制
+func WithMaxCost(n int64) Option {
+	return func(o *options) {
+		o.maxCost = n
+	}
+}
+
+// WithSizer 设置计算key成本的方法，默认为DefaultSizer
+func WithSizer(sizer Sizer) Option {
+	return func(o *options) {
+		o.sizer = sizer
+	}
+}
+
+// WithEvictionPolicy 设置淘汰策略，每个分片单独创建一个实例，仅在设置了最大key数量或最大总成本时生效
+func WithEvictionPolicy(factory PolicyFactory) Option {
+	return func(o *options) {
+		o.policy = factory
+	}
+}
+
+// WithRemovalListener 设置key被移除时的回调，在分片锁外同步调用
+func WithRemovalListener(listener RemovalListener) Option {
+	return func(o *options) {
+		o.listener = listener
+		o.asyncSize = 0
+	}
+}
+
+// WithAsyncRemovalListener 设置key被移除时的回调，通过容量为size的队列在单独的goroutine中调用，
+// 队列满时在移除key的调用方同步调用，Close后改为同步调用
+func WithAsyncRemovalListener(listener RemovalListener, size int) Option {
+	return func(o *options) {
+		o.listener = listener
+		o.asyncSize = size
+		if o.asyncSize < 1 {
+			o.asyncSize = 1
+		}
+	}
+}
+
+// WithLoadTimeout 设置LoadCtx中加载函数的超时时间，超时后取消加载函数的ctx，d <= 0 表示不超时
+func WithLoadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.loadTimeout = d
+	}
+}
+
+// WithNegativeCache 加载函数返回的错误满足match时缓存该错误ttl时间，期间Get及Load直接返回该错误，
+// match为nil时只缓存ErrNil
+func WithNegativeCache(ttl time.Duration, match func(err error) bool) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+		o.negativeMatch = match
+		if o.negativeMatch == nil {
+			o.negativeMatch = ErrIsNotFound
+		}
+	}
+}
+
+// WithRefreshErrorHandler 设置异步加载失败时的回调
+func WithRefreshErrorHandler(handle func(key string, err error)) Option {
+	return func(o *options) {
+		o.onRefreshErr = handle
+	}
+}
+
+// WithTTLJitter 写入带过期时间的key时随机延长[0, ratio*ttl]，避免同时写入的key同时过期
+func WithTTLJitter(ratio float64) Option {
+	return func(o *options) {
+		o.jitterRatio = ratio
+	}
+}
+
+// WithTTLJitterRange 写入带过期时间的key时随机延长[0, max]，与WithTTLJitter同时设置时取较大的范围
+func WithTTLJitterRange(max time.Duration) Option {
+	return func(o *options) {
+		o.jitterMax = max
+	}
+}
+
+// WithJitterSeed 使用固定种子生成随机延长时间，相同的写入顺序得到相同的过期时间
+func WithJitterSeed(seed int64) Option {
+	return func(o *options) {
+		o.jitterSeed = seed
+		o.jitterSeeded = true
+	}
+}
+
+// WithExpiryStrategy 设置NewCacheWithGC主动清理过期key的方式，默认为ExpireByTimingWheel
+func WithExpiryStrategy(strategy ExpiryStrategy) Option {
+	return func(o *options) {
+		o.expiry = strategy
+	}
+}
+
+// WithClock 设置时间源，默认使用系统时间
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithCoarseClock 使用按precision更新的CoarseClock代替系统时间，减少读写时调用time.Now的开销，
+// 过期判断的精度降为precision，时钟随缓存Close停止
+func WithCoarseClock(precision time.Duration) Option {
+	return func(o *options) {
+		o.coarsePrecision = precision
+		if o.coarsePrecision <= 0 {
+			o.coarsePrecision = time.Millisecond
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		policy: NewLRUPolicy,
+		sizer:  DefaultSizer,
+		clock:  _realClock,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.coarsePrecision > 0 {
+		o.ownedClock = NewCoarseClock(o.coarsePrecision)
+		o.clock = o.ownedClock
+	}
+	return o
+}
+
+// sharedMaxEntries 每个分片的最大key数量，向下取整保证总数不超过maxEntries，最少为1
+func (o *options) sharedMaxEntries(sharedNum int) int {
+	if o.maxEntries <= 0 {
+		return 0
+	}
+	if n := o.maxEntries / sharedNum; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// sharedMaxCost 每个分片的最大总成本
+func (o *options) sharedMaxCost(sharedNum int) int64 {
+	if o.maxCost <= 0 {
+		return 0
+	}
+	return (o.maxCost + int64(sharedNum) - 1) / int64(sharedNum)
+}
+
+type loadOptions struct {
+	refreshAhead float64
+	xfetchBeta   float64
+}
+
+type LoadOption func(*loadOptions)
+
+// WithRefreshAhead 读取时已经过ttl的ratio比例则异步刷新，同一key同时只有一个刷新，0 < ratio < 1
+func WithRefreshAhead(ratio float64) LoadOption {
+	return func(o *loadOptions) {
+		if ratio > 0 && ratio < 1 {
+			o.refreshAhead = ratio
+		}
+	}
+}
+
+// WithXFetch 读取时按XFetch算法概率性提前同步加载：now - delta*beta*ln(rand()) >= expAt，
+// delta为上一次加载的耗时，beta越大越倾向于提前加载，默认推荐1
+func WithXFetch(beta float64) LoadOption {
+	return func(o *loadOptions) {
+		if beta > 0 {
+			o.xfetchBeta = beta
+		}
+	}
+}
+
+func newLoadOptions(opts []LoadOption) loadOptions {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// shouldRefresh 未过期的key是否需要提前刷新
+func (o *loadOptions) shouldRefresh(now, expAt int64, meta entryMeta) bool {
+	if o.refreshAhead <= 0 || expAt < 0 || meta.ttl <= 0 {
+		return false
+	}
+	refreshAt := expAt - meta.ttl + int64(float64(meta.ttl)*o.refreshAhead)
+	return now >= refreshAt
+}
+
+// shouldRecompute 未过期的key按XFetch算法是否需要提前加载
+func (o *loadOptions) shouldRecompute(now, expAt int64, meta entryMeta) bool {
+	if o.xfetchBeta <= 0 || expAt < 0 || meta.delta <= 0 {
+		return false
+	}
+	gap := -float64(meta.delta) * o.xfetchBeta * math.Log(1-rand.Float64())
+	return float64(now)+gap >= float64(expAt)
+}
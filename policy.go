@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"container/heap"
+	"math/rand"
+	"time"
+)
+
+var (
+	_ EvictionPolicy = (*lruPolicy)(nil)
+	_ EvictionPolicy = (*fifoPolicy)(nil)
+	_ EvictionPolicy = (*lfuPolicy)(nil)
+	_ EvictionPolicy = (*randomPolicy)(nil)
+	_ EvictionPolicy = (*s3FIFOPolicy)(nil)
+)
+
+// EvictionPolicy 淘汰策略，每个分片持有一个实例，所有方法都在分片写锁内调用，无需并发安全
+type EvictionPolicy interface {
+	// Access key被读取或覆盖写入，读取记录经过缓冲，可能有延迟或丢弃
+	Access(key string)
+	// Insert 新key写入
+	Insert(key string)
+	// Delete key被删除或过期，key不存在时忽略
+	Delete(key string)
+	// Victim 选出并移除一个待淘汰的key，没有可淘汰的key时返回false
+	Victim() (string, bool)
+}
+
+// PolicyFactory 创建淘汰策略，capacity为分片的最大key数量，只限制总成本时为分片的初始容量
+type PolicyFactory func(capacity int) EvictionPolicy
+
+// policyResizer 容量可调整的淘汰策略，只限制总成本时分片按淘汰时的key数量调整容量
+type policyResizer interface {
+	resize(capacity int)
+}
+
+type policyNode struct {
+	key  string
+	freq int
+	main bool
+	prev *policyNode
+	next *policyNode
+}
+
+// policyList 双向链表，表头为最新，表尾为最旧
+type policyList struct {
+	root policyNode
+	len  int
+}
+
+func (l *policyList) init() {
+	l.root.prev = &l.root
+	l.root.next = &l.root
+}
+
+func (l *policyList) pushFront(node *policyNode) {
+	node.prev = &l.root
+	node.next = l.root.next
+	l.root.next.prev = node
+	l.root.next = node
+	l.len++
+}
+
+func (l *policyList) remove(node *policyNode) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.prev = nil
+	node.next = nil
+	l.len--
+}
+
+func (l *policyList) back() *policyNode {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// lruPolicy 淘汰最久未访问的key
+type lruPolicy struct {
+	list  policyList
+	nodes map[string]*policyNode
+}
+
+func NewLRUPolicy(capacity int) EvictionPolicy {
+	return newLRU(capacity)
+}
+
+func newLRU(capacity int) *lruPolicy {
+	l := &lruPolicy{
+		nodes: make(map[string]*policyNode, capacity),
+	}
+	l.list.init()
+	return l
+}
+
+func (l *lruPolicy) Len() int {
+	return l.list.len
+}
+
+func (l *lruPolicy) Access(key string) {
+	node, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	l.list.remove(node)
+	l.list.pushFront(node)
+}
+
+func (l *lruPolicy) Insert(key string) {
+	if _, ok := l.nodes[key]; ok {
+		l.Access(key)
+		return
+	}
+	node := &policyNode{key: key}
+	l.nodes[key] = node
+	l.list.pushFront(node)
+}
+
+func (l *lruPolicy) Delete(key string) {
+	node, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	delete(l.nodes, key)
+	l.list.remove(node)
+}
+
+func (l *lruPolicy) Victim() (string, bool) {
+	node := l.list.back()
+	if node == nil {
+		return _EMPTY_STR, false
+	}
+	delete(l.nodes, node.key)
+	l.list.remove(node)
+	return node.key, true
+}
+
+// fifoPolicy 按写入顺序淘汰，忽略访问
+type fifoPolicy struct {
+	*lruPolicy
+}
+
+func NewFIFOPolicy(capacity int) EvictionPolicy {
+	return fifoPolicy{newLRU(capacity)}
+}
+
+func (fifoPolicy) Access(string) {}
+
+func (f fifoPolicy) Insert(key string) {
+	if _, ok := f.nodes[key]; ok {
+		return
+	}
+	f.lruPolicy.Insert(key)
+}
+
+// randomPolicy 随机淘汰
+type randomPolicy struct {
+	keys  []string
+	index map[string]int
+	rand  *rand.Rand
+}
+
+func NewRandomPolicy(capacity int) EvictionPolicy {
+	return &randomPolicy{
+		keys:  make([]string, 0, capacity),
+		index: make(map[string]int, capacity),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (r *randomPolicy) Access(string) {}
+
+func (r *randomPolicy) Insert(key string) {
+	if _, ok := r.index[key]; ok {
+		return
+	}
+	r.index[key] = len(r.keys)
+	r.keys = append(r.keys, key)
+}
+
+func (r *randomPolicy) Delete(key string) {
+	i, ok := r.index[key]
+	if !ok {
+		return
+	}
+	last := len(r.keys) - 1
+	r.keys[i] = r.keys[last]
+	r.index[r.keys[i]] = i
+	r.keys[last] = _EMPTY_STR
+	r.keys = r.keys[:last]
+	delete(r.index, key)
+}
+
+func (r *randomPolicy) Victim() (string, bool) {
+	if len(r.keys) == 0 {
+		return _EMPTY_STR, false
+	}
+	key := r.keys[r.rand.Intn(len(r.keys))]
+	r.Delete(key)
+	return key, true
+}
+
+// lfuPolicy 淘汰访问次数最少的key，次数相同时淘汰最久未访问的
+type lfuPolicy struct {
+	items lfuHeap
+	nodes map[string]*lfuItem
+	seq   uint64
+}
+
+type lfuItem struct {
+	key   string
+	freq  int
+	seq   uint64
+	index int
+}
+
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	item := x.(*lfuItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old) - 1
+	item := old[n]
+	old[n] = nil
+	*h = old[:n]
+	return item
+}
+
+func NewLFUPolicy(capacity int) EvictionPolicy {
+	return &lfuPolicy{
+		items: make(lfuHeap, 0, capacity),
+		nodes: make(map[string]*lfuItem, capacity),
+	}
+}
+
+func (l *lfuPolicy) Access(key string) {
+	item, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	l.seq++
+	item.freq++
+	item.seq = l.seq
+	heap.Fix(&l.items, item.index)
+}
+
+func (l *lfuPolicy) Insert(key string) {
+	if _, ok := l.nodes[key]; ok {
+		l.Access(key)
+		return
+	}
+	l.seq++
+	item := &lfuItem{key: key, freq: 1, seq: l.seq}
+	l.nodes[key] = item
+	heap.Push(&l.items, item)
+}
+
+func (l *lfuPolicy) Delete(key string) {
+	item, ok := l.nodes[key]
+	if !ok {
+		return
+	}
+	delete(l.nodes, key)
+	heap.Remove(&l.items, item.index)
+}
+
+func (l *lfuPolicy) Victim() (string, bool) {
+	if len(l.items) == 0 {
+		return _EMPTY_STR, false
+	}
+	item := heap.Pop(&l.items).(*lfuItem)
+	delete(l.nodes, item.key)
+	return item.key, true
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"testing"
+)
+
+func evictAll(p EvictionPolicy) []string {
+	var keys []string
+	for {
+		key, ok := p.Victim()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func assertKeys(t *testing.T, name string, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: victims %v, want %v", name, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s: victims %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLRUPolicy(t *testing.T) {
+	p := NewLRUPolicy(4)
+	p.Insert("t1")
+	p.Insert("t2")
+	p.Insert("t3")
+	p.Insert("t4")
+	p.Access("t1")
+	p.Delete("t3")
+	p.Access("t5")
+	assertKeys(t, "lru", evictAll(p), []string{"t2", "t4", "t1"})
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	p := NewFIFOPolicy(4)
+	p.Insert("t1")
+	p.Insert("t2")
+	p.Insert("t3")
+	p.Access("t1")
+	p.Insert("t1")
+	p.Delete("t2")
+	assertKeys(t, "fifo", evictAll(p), []string{"t1", "t3"})
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := NewLFUPolicy(4)
+	p.Insert("t1")
+	p.Insert("t2")
+	p.Insert("t3")
+	p.Insert("t4")
+	p.Access("t1")
+	p.Access("t1")
+	p.Access("t2")
+	p.Access("t3")
+	p.Delete("t4")
+	assertKeys(t, "lfu", evictAll(p), []string{"t2", "t3", "t1"})
+}
+
+func TestRandomPolicy(t *testing.T) {
+	p := NewRandomPolicy(4)
+	p.Insert("t1")
+	p.Insert("t2")
+	p.Insert("t3")
+	p.Insert("t3")
+	p.Delete("t2")
+
+	keys := evictAll(p)
+	if len(keys) != 2 {
+		t.Fatal("random: victims ", keys)
+	}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		seen[key] = true
+	}
+	if !seen["t1"] || !seen["t3"] {
+		t.Fatal("random: victims ", keys)
+	}
+}
+
+func TestS3FIFOPolicy(t *testing.T) {
+	p := NewS3FIFOPolicy(10).(*s3FIFOPolicy)
+	p.Insert("t1")
+	p.Insert("t2")
+	p.Insert("t3")
+	p.Access("t2")
+
+	// small队列中未访问的t1被淘汰，记录到ghost
+	key, _ := p.Victim()
+	if key != "t1" {
+		t.Fatal("s3fifo: victim should be t1, got ", key)
+	}
+	if _, ok := p.ghost.nodes["t1"]; !ok {
+		t.Fatal("s3fifo: t1 should be in ghost")
+	}
+
+	// t2被访问过晋升到main，t3被淘汰
+	key, _ = p.Victim()
+	if key != "t3" {
+		t.Fatal("s3fifo: victim should be t3, got ", key)
+	}
+	if node := p.nodes["t2"]; node == nil || !node.main {
+		t.Fatal("s3fifo: t2 should be in main")
+	}
+
+	// ghost中的key再次写入直接进入main
+	p.Insert("t1")
+	if node := p.nodes["t1"]; node == nil || !node.main {
+		t.Fatal("s3fifo: t1 should be in main")
+	}
+
+	p.Delete("t2")
+	assertKeys(t, "s3fifo", evictAll(p), []string{"t1"})
+}
+
+func TestCache_EvictionPolicy(t *testing.T) {
+	policies := map[string]PolicyFactory{
+		"lru":    NewLRUPolicy,
+		"lfu":    NewLFUPolicy,
+		"fifo":   NewFIFOPolicy,
+		"random": NewRandomPolicy,
+		"s3fifo": NewS3FIFOPolicy,
+	}
+	for name, factory := range policies {
+		cache := NewCache(2, 10, WithMaxEntries(20), WithEvictionPolicy(factory))
+		for i := 0; i < 200; i++ {
+			key := string(rune('a'+i%26)) + string(rune('a'+i/26))
+			cache.Set(key, i)
+			_, _ = cache.Get(key)
+		}
+		var num int
+		cache.Scan(func(key string, value interface{}, expAt int64) {
+			num++
+		})
+		if num > 20 {
+			t.Fatalf("%s: cache entries should be limited to 20, got %d", name, num)
+		}
+	}
+}
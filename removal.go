@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"sync"
+)
+
+// RemovalReason key被移除的原因
+type RemovalReason uint8
+
+const (
+	RemovalExpired  RemovalReason = iota + 1 // 过期
+	RemovalEvicted                           // 超出容量被淘汰
+	RemovalDeleted                           // 调用Del删除
+	RemovalReplaced                          // 被新的value覆盖
+)
+
+func (r RemovalReason) String() string {
+	switch r {
+	case RemovalExpired:
+		return "expired"
+	case RemovalEvicted:
+		return "evicted"
+	case RemovalDeleted:
+		return "deleted"
+	case RemovalReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// RemovalListener key被移除时的回调，在分片锁外调用
+type RemovalListener func(key string, value interface{}, reason RemovalReason)
+
+type removal struct {
+	key    string
+	value  interface{}
+	reason RemovalReason
+}
+
+// removalDispatcher 有界异步分发，队列满时在调用方同步调用，关闭后改为同步调用
+// 同步调用时回调不保证按移除的顺序执行，回调中可以调用缓存的方法
+type removalDispatcher struct {
+	listener RemovalListener
+	queue    chan removal
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
+func newRemovalDispatcher(listener RemovalListener, size int) *removalDispatcher {
+	if size < 1 {
+		size = 1
+	}
+	d := &removalDispatcher{
+		listener: listener,
+		queue:    make(chan removal, size),
+		done:     make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+func (d *removalDispatcher) run() {
+	defer close(d.done)
+	for r := range d.queue {
+		d.listener(r.key, r.value, r.reason)
+	}
+}
+
+func (d *removalDispatcher) Dispatch(key string, value interface{}, reason RemovalReason) {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		d.listener(key, value, reason)
+		return
+	}
+	select {
+	case d.queue <- removal{key: key, value: value, reason: reason}:
+		d.mu.RUnlock()
+	default: // 队列满时不阻塞，避免回调中移除key时等待自身
+		d.mu.RUnlock()
+		d.listener(key, value, reason)
+	}
+}
+
+// Close 等待队列中的回调执行完成
+func (d *removalDispatcher) Close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	close(d.queue)
+	d.mu.Unlock()
+	<-d.done
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type removalRecorder struct {
+	mu      sync.Mutex
+	reasons map[string]RemovalReason
+	values  map[string]interface{}
+}
+
+func newRemovalRecorder() *removalRecorder {
+	return &removalRecorder{
+		reasons: make(map[string]RemovalReason),
+		values:  make(map[string]interface{}),
+	}
+}
+
+func (r *removalRecorder) listen(key string, value interface{}, reason RemovalReason) {
+	r.mu.Lock()
+	r.reasons[key] = reason
+	r.values[key] = value
+	r.mu.Unlock()
+}
+
+func (r *removalRecorder) reason(key string) RemovalReason {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reasons[key]
+}
+
+func TestCache_RemovalListener(t *testing.T) {
+	r := newRemovalRecorder()
+	var cache *Cache
+	cache = NewCache(2, 10, WithMaxEntries(2), WithRemovalListener(func(key string, value interface{},
+		reason RemovalReason) {
+		// 回调在分片锁外执行，可以重新访问缓存
+		_, _ = cache.Get(key)
+		r.listen(key, value, reason)
+	}))
+
+	// 覆盖写入
+	cache.Set("t1", 1)
+	cache.Set("t1", 2)
+	if r.reason("t1") != RemovalReplaced || r.values["t1"] != 1 {
+		t.Fatal("t1 should be replaced")
+	}
+
+	// Del删除
+	cache.Del("t1")
+	if r.reason("t1") != RemovalDeleted || r.values["t1"] != 2 {
+		t.Fatal("t1 should be deleted")
+	}
+
+	// Get时惰性删除
+	cache.SetEx("t2", 2, time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	_, _ = cache.Get("t2")
+	if r.reason("t2") != RemovalExpired {
+		t.Fatal("t2 should be expired")
+	}
+
+	// 超出容量淘汰
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		cache.Set(key, key)
+	}
+	var evicted int
+	for _, reason := range r.reasons {
+		if reason == RemovalEvicted {
+			evicted++
+		}
+	}
+	if evicted == 0 {
+		t.Fatal("keys should be evicted")
+	}
+}
+
+func TestCache_RemovalListenerWithGC(t *testing.T) {
+	r := newRemovalRecorder()
+	cache := NewCacheWithGC(2, 10, time.Millisecond, WithRemovalListener(r.listen))
+	defer cache.Close()
+
+	// 时间轮清理过期key
+	cache.SetEx("t1", 1, time.Millisecond)
+	for i := 0; i < 100 && r.reason("t1") == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if r.reason("t1") != RemovalExpired {
+		t.Fatal("t1 should be expired by timer")
+	}
+}
+
+func TestCache_AsyncRemovalListener(t *testing.T) {
+	r := newRemovalRecorder()
+	cache := NewCache(2, 10, WithAsyncRemovalListener(r.listen, 2))
+	for i := 0; i < 10; i++ {
+		key := string(rune('a' + i))
+		cache.Set(key, i)
+		cache.Del(key)
+	}
+
+	// Close等待队列中的回调执行完成
+	cache.Close()
+	r.mu.Lock()
+	num := len(r.reasons)
+	r.mu.Unlock()
+	if num != 10 {
+		t.Fatal("removal listener should be called 10 times, got ", num)
+	}
+
+	// 关闭后同步调用
+	cache.Set("z", 1)
+	cache.Del("z")
+	if r.reason("z") != RemovalDeleted {
+		t.Fatal("z should be deleted")
+	}
+}
+
+func TestCache_AsyncRemovalListener_Reentrant(t *testing.T) {
+	r := newRemovalRecorder()
+	var cache *Cache
+	cache = NewCache(2, 10, WithAsyncRemovalListener(func(key string, value interface{}, reason RemovalReason) {
+		// 回调中移除其他key，队列满时不会等待自身
+		if key == "a" {
+			for i := 1; i < 10; i++ {
+				cache.Del(string(rune('a' + i)))
+			}
+		}
+		r.listen(key, value, reason)
+	}, 1))
+	for i := 0; i < 10; i++ {
+		cache.Set(string(rune('a'+i)), i)
+	}
+	cache.Del("a")
+
+	// 等待回调在单独的goroutine中执行完成
+	deadline := time.Now().Add(time.Second)
+	for r.reason("a") != RemovalDeleted {
+		if time.Now().After(deadline) {
+			t.Fatal("reentrant removal listener should not deadlock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cache.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reentrant removal listener should not deadlock")
+	}
+	for i := 0; i < 10; i++ {
+		key := string(rune('a' + i))
+		if r.reason(key) != RemovalDeleted {
+			t.Fatalf("%s should be deleted", key)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package cache
+
+const (
+	_s3FIFOSmallRatio = 10 // small队列占容量的百分比
+	_s3FIFOMaxFreq    = 3
+)
+
+var _ policyResizer = (*s3FIFOPolicy)(nil)
+
+// s3FIFOPolicy S3-FIFO淘汰策略
+// 新key先进入small队列，在small中被再次访问的key晋升到main队列，未被访问的key淘汰并记录到ghost队列，
+// ghost中的key再次写入时直接进入main队列；main队列按FIFO淘汰，访问过的key重新插入队头
+type s3FIFOPolicy struct {
+	small    policyList
+	main     policyList
+	ghost    *lruPolicy
+	nodes    map[string]*policyNode
+	smallCap int
+	ghostCap int
+}
+
+func NewS3FIFOPolicy(capacity int) EvictionPolicy {
+	smallCap, ghostCap := s3FIFOCaps(capacity)
+	p := &s3FIFOPolicy{
+		ghost:    newLRU(ghostCap),
+		nodes:    make(map[string]*policyNode, capacity),
+		smallCap: smallCap,
+		ghostCap: ghostCap,
+	}
+	p.small.init()
+	p.main.init()
+	return p
+}
+
+func s3FIFOCaps(capacity int) (smallCap, ghostCap int) {
+	smallCap = capacity * _s3FIFOSmallRatio / 100
+	if smallCap < 1 {
+		smallCap = 1
+	}
+	ghostCap = capacity - smallCap
+	if ghostCap < 1 {
+		ghostCap = 1
+	}
+	return smallCap, ghostCap
+}
+
+// resize 调整small及ghost的容量，ghost超出时淘汰最旧的记录
+func (p *s3FIFOPolicy) resize(capacity int) {
+	p.smallCap, p.ghostCap = s3FIFOCaps(capacity)
+	for p.ghost.Len() > p.ghostCap {
+		p.ghost.Victim()
+	}
+}
+
+func (p *s3FIFOPolicy) Access(key string) {
+	node, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	if node.freq < _s3FIFOMaxFreq {
+		node.freq++
+	}
+}
+
+func (p *s3FIFOPolicy) Insert(key string) {
+	if _, ok := p.nodes[key]; ok {
+		p.Access(key)
+		return
+	}
+	node := &policyNode{key: key}
+	p.nodes[key] = node
+	if _, ok := p.ghost.nodes[key]; ok {
+		p.ghost.Delete(key)
+		node.main = true
+		p.main.pushFront(node)
+		return
+	}
+	p.small.pushFront(node)
+}
+
+func (p *s3FIFOPolicy) Delete(key string) {
+	node, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	delete(p.nodes, key)
+	if node.main {
+		p.main.remove(node)
+	} else {
+		p.small.remove(node)
+	}
+}
+
+func (p *s3FIFOPolicy) Victim() (string, bool) {
+	for len(p.nodes) > 0 {
+		if p.small.len >= p.smallCap || p.main.len == 0 {
+			if key, ok := p.evictSmall(); ok {
+				return key, true
+			}
+			continue
+		}
+		if key, ok := p.evictMain(); ok {
+			return key, true
+		}
+	}
+	return _EMPTY_STR, false
+}
+
+// evictSmall small队尾被访问过则晋升到main，否则淘汰并记录到ghost
+func (p *s3FIFOPolicy) evictSmall() (string, bool) {
+	node := p.small.back()
+	if node == nil {
+		return _EMPTY_STR, false
+	}
+	p.small.remove(node)
+	if node.freq > 0 {
+		node.freq = 0
+		node.main = true
+		p.main.pushFront(node)
+		return _EMPTY_STR, false
+	}
+
+	delete(p.nodes, node.key)
+	p.ghost.Insert(node.key)
+	if p.ghost.Len() > p.ghostCap {
+		p.ghost.Victim()
+	}
+	return node.key, true
+}
+
+// evictMain main队尾被访问过则重新插入队头，否则淘汰
+func (p *s3FIFOPolicy) evictMain() (string, bool) {
+	node := p.main.back()
+	if node == nil {
+		return _EMPTY_STR, false
+	}
+	p.main.remove(node)
+	if node.freq > 0 {
+		node.freq--
+		p.main.pushFront(node)
+		return _EMPTY_STR, false
+	}
+	delete(p.nodes, node.key)
+	return node.key, true
+}
@@ -1,33 +1,115 @@
 package cache
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const _readBufSize = 64 // 读操作缓冲区大小，写满后批量更新访问顺序
+
 type shared struct {
+	stats   sharedStats // 原子操作的字段放在首位保证64位对齐
 	entries map[string]*entry
-	//count   int
-	//delCalled int
-	mu     sync.RWMutex
-	loader group
+	mu      sync.RWMutex
+	loader  group
+
+	maxEntries int
+	maxCost    int64
+	cost       int64
+	sizer      Sizer
+	policy     EvictionPolicy
+	policyCap  int // 淘汰策略当前的容量
+	readBuf    []string
+	readIdx    int32
+
+	clock    Clock
+	onRemove RemovalListener
+	removed  []removal                     // 写锁内记录被移除的key，解锁后回调
+	schedule func(key string, expAt int64) // 写锁内同步key的过期时间到expirer，expAt < 0 表示移除，nil 表示没有expirer
 }
 
 type entry struct {
-	value interface{}
-	expAt int64
+	access int64 // 滑动过期的key最后一次读取的时间，原子操作的字段放在首位保证64位对齐
+	value  interface{}
+	expAt  int64
+	cost   int64
+	meta   entryMeta
+}
+
+// entryMeta 写入时记录的元数据
+type entryMeta struct {
+	ttl      int64 // 写入时的ttl，0 表示未记录
+	delta    int64 // 加载耗时
+	idle     int64 // 滑动过期时间，0 表示不滑动过期
+	deadline int64 // 滑动过期的key最晚的过期时间，0 表示不限制
+	stale    int64 // 过期后继续保留的时间，供异步加载返回旧value，0 表示不保留，< 0 表示一直保留
+}
+
+// expireAt 滑动过期的key按最后一次读取的时间计算过期时间
+func (e *entry) expireAt() int64 {
+	if e.meta.idle <= 0 {
+		return e.expAt
+	}
+	expAt := atomic.LoadInt64(&e.access) + e.meta.idle
+	if e.meta.deadline > 0 && e.meta.deadline < expAt {
+		return e.meta.deadline
+	}
+	return expAt
+}
+
+// removeAt key可以被删除的时间，过期后继续保留stale时间，-1 表示不删除
+func (e *entry) removeAt() int64 {
+	expAt := e.expireAt()
+	if expAt < 0 || e.meta.stale == 0 {
+		return expAt
+	}
+	if e.meta.stale < 0 {
+		return -1
+	}
+	return expAt + e.meta.stale
 }
 
 func newShared(cap int) *shared {
 	return &shared{
 		entries: make(map[string]*entry, cap),
+		clock:   _realClock,
 	}
 }
 
+// configure 按配置初始化分片，需在使用前调用
+func (s *shared) configure(o *options, sharedNum, sharedCap int, onRemove RemovalListener) {
+	s.onRemove = onRemove
+	s.clock = o.clock
+	s.maxEntries = o.sharedMaxEntries(sharedNum)
+	s.maxCost = o.sharedMaxCost(sharedNum)
+	s.sizer = o.sizer
+	if s.maxEntries <= 0 && s.maxCost <= 0 {
+		return
+	}
+
+	capacity := s.maxEntries
+	if capacity <= 0 {
+		capacity = sharedCap
+	}
+	s.policy = o.policy(capacity)
+	s.policyCap = capacity
+	s.readBuf = make([]string, _readBufSize)
+}
+
 func (s *shared) Get(key string) (interface{}, bool) {
+	val, _, _, ok := s.GetMeta(key)
+	return val, ok
+}
+
+// GetMeta 与Get相同，同时返回过期时间及元数据
+func (s *shared) GetMeta(key string) (interface{}, int64, entryMeta, bool) {
 	var (
 		val   interface{}
 		expAt int64
+		meta  entryMeta
+		drain bool
 	)
 
 	s.mu.RLock()
@@ -35,27 +117,106 @@ func (s *shared) Get(key string) (interface{}, bool) {
 	r, ok := s.entries[key]
 	if !ok {
 		s.mu.RUnlock()
-		return nil, false
+		atomic.AddUint64(&s.stats.misses, 1)
+		return nil, 0, meta, false
 	}
 
 	val = r.value
-	expAt = r.expAt
+	expAt = r.expireAt()
+	meta = r.meta
+	if meta.idle > 0 { // 未过期时延长滑动过期时间
+		now := s.clock.Now().UnixNano()
+		if expAt > now {
+			atomic.StoreInt64(&r.access, now)
+			expAt = r.expireAt()
+		}
+	}
+	if s.policy != nil {
+		drain = s.recordAccess(key)
+	}
 	s.mu.RUnlock()
 
+	if drain {
+		s.mu.Lock()
+		s.drainAccess()
+		s.mu.Unlock()
+	}
+
 	if expAt < 0 {
-		return val, true
+		atomic.AddUint64(&s.stats.hits, 1)
+		return val, expAt, meta, true
 	}
 
-	now := time.Now().UnixNano()
+	now := s.clock.Now().UnixNano()
 	if expAt > now {
-		return val, true
+		atomic.AddUint64(&s.stats.hits, 1)
+		return val, expAt, meta, true
 	}
 
+	atomic.AddUint64(&s.stats.misses, 1)
 	s.mu.Lock()
-	s.delBefore(key, expAt)
+	s.delBefore(key, now)
+	removed := s.takeRemoved()
 	s.mu.Unlock()
+	s.notify(removed)
+
+	return nil, 0, entryMeta{}, false
+}
+
+// GetMany 在一次读锁内读取keys中下标为indexes的key，未过期的key写入values及found的相同下标，过期的key在之后的一次写锁内删除
+func (s *shared) GetMany(keys []string, indexes []int, values []interface{}, found []bool) {
+	var (
+		expired      []string
+		hits, misses uint64
+		drain        bool
+	)
+
+	var now int64 // 只在有带过期时间的key时获取
+	s.mu.RLock()
+	for _, i := range indexes {
+		r, ok := s.entries[keys[i]]
+		if !ok {
+			misses++
+			continue
+		}
+		if expAt := r.expireAt(); expAt >= 0 {
+			if now == 0 {
+				now = s.clock.Now().UnixNano()
+			}
+			if expAt <= now {
+				misses++
+				expired = append(expired, keys[i])
+				continue
+			}
+			if r.meta.idle > 0 {
+				atomic.StoreInt64(&r.access, now)
+			}
+		}
+		if s.policy != nil && s.recordAccess(keys[i]) {
+			drain = true
+		}
+		hits++
+		values[i] = r.value
+		found[i] = true
+	}
+	s.mu.RUnlock()
 
-	return nil, false
+	atomic.AddUint64(&s.stats.hits, hits)
+	atomic.AddUint64(&s.stats.misses, misses)
+	if !drain && len(expired) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	if drain {
+		s.drainAccess()
+	}
+	for _, key := range expired {
+		s.delBefore(key, now)
+	}
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
 }
 
 func (s *shared) GetIgnoreExp(key string) (interface{}, int64, bool) {
@@ -63,66 +224,476 @@ func (s *shared) GetIgnoreExp(key string) (interface{}, int64, bool) {
 	r, ok := s.entries[key]
 	if !ok {
 		s.mu.RUnlock()
+		atomic.AddUint64(&s.stats.misses, 1)
 		return nil, 0, false
 	}
-	val, expAt := r.value, r.expAt
+	val, expAt := r.value, r.expireAt()
+	s.mu.RUnlock()
+	if expAt >= 0 && expAt <= s.clock.Now().UnixNano() { // 返回过期的value记为未命中
+		atomic.AddUint64(&s.stats.misses, 1)
+	} else {
+		atomic.AddUint64(&s.stats.hits, 1)
+	}
+	return val, expAt, true
+}
+
+// Peek 不更新访问顺序及统计，不删除过期的key
+func (s *shared) Peek(key string) (interface{}, int64, bool) {
+	s.mu.RLock()
+	r, ok := s.entries[key]
+	if !ok {
+		s.mu.RUnlock()
+		return nil, 0, false
+	}
+	val, expAt := r.value, r.expireAt()
 	s.mu.RUnlock()
 	return val, expAt, true
 }
 
 func (s *shared) Set(key string, value interface{}, expAt int64) {
+	cost := s.sizeOf(key, value)
+	s.SetWithCost(key, value, expAt, cost)
+}
+
+func (s *shared) SetWithCost(key string, value interface{}, expAt int64, cost int64) {
+	s.set(key, value, expAt, cost, entryMeta{})
+}
+
+// SetWithMeta 写入并记录元数据
+func (s *shared) SetWithMeta(key string, value interface{}, expAt int64, meta entryMeta) {
+	cost := s.sizeOf(key, value)
+	s.set(key, value, expAt, cost, meta)
+}
+
+func (s *shared) set(key string, value interface{}, expAt int64, cost int64, meta entryMeta) {
 	s.mu.Lock()
+	s.setLocked(key, value, expAt, cost, meta, true)
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
+}
+
+// setItem 批量写入的key
+type setItem struct {
+	key   string
+	value interface{}
+	expAt int64
+	meta  entryMeta
+}
+
+// SetMany 在一次写锁内写入items中下标为indexes的key
+func (s *shared) SetMany(items []setItem, indexes []int) {
+	s.mu.Lock()
+	for _, i := range indexes {
+		cost := s.sizeOf(items[i].key, items[i].value)
+		s.setLocked(items[i].key, items[i].value, items[i].expAt, cost, items[i].meta, true)
+	}
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
+}
+
+// setLocked 需在写锁内调用，replace为false时覆盖已有的key不回调RemovalReplaced
+func (s *shared) setLocked(key string, value interface{}, expAt int64, cost int64, meta entryMeta, replace bool) {
+	atomic.AddUint64(&s.stats.sets, 1)
 
 	item, ok := s.entries[key]
 	if ok {
+		if replace {
+			s.record(key, item.value, RemovalReplaced)
+		}
+		s.cost += cost - item.cost
 		item.value = value
 		item.expAt = expAt
+		item.cost = cost
+		item.meta = meta
+		if meta.idle > 0 {
+			atomic.StoreInt64(&item.access, s.clock.Now().UnixNano())
+		}
+		s.reschedule(key, item.removeAt())
+		if s.policy != nil {
+			s.policy.Access(key)
+			s.evict()
+		}
 	} else {
-		s.entries[key] = &entry{
+		item = &entry{
 			value: value,
 			expAt: expAt,
+			cost:  cost,
+			meta:  meta,
+		}
+		if meta.idle > 0 {
+			item.access = s.clock.Now().UnixNano()
+		}
+		s.entries[key] = item
+		s.cost += cost
+		s.reschedule(key, item.removeAt())
+		if s.policy != nil {
+			s.policy.Insert(key)
+			s.evict()
+		}
+	}
+}
+
+// computeAction Compute中对key的修改
+type computeAction int
+
+const (
+	computeNone computeAction = iota
+	computeSet
+	computeUpdate // 与computeSet相同，覆盖已有的key时不回调RemovalReplaced，用于原地修改value
+	computeDelete
+)
+
+// computeFunc 在写锁内调用，item为nil表示key不存在或已过期，不能修改item，
+// 返回computeSet或computeUpdate时按value、expAt及meta写入，返回computeDelete时删除key
+type computeFunc func(item *entry) (value interface{}, expAt int64, meta entryMeta, action computeAction)
+
+// Compute 在写锁内读取并修改key，返回实际执行的修改及写入的过期时间，fn中不能调用缓存的方法
+func (s *shared) Compute(key string, fn computeFunc) (computeAction, int64) {
+	var (
+		action  computeAction
+		expAt   int64
+		removed []removal
+	)
+	func() {
+		s.mu.Lock()
+		defer func() { // fn panic时释放锁
+			removed = s.takeRemoved()
+			s.mu.Unlock()
+		}()
+
+		item, ok := s.live(key)
+		if !ok {
+			item = nil
+		}
+		var (
+			value interface{}
+			meta  entryMeta
+		)
+		value, expAt, meta, action = fn(item)
+		switch action {
+		case computeSet, computeUpdate:
+			s.setLocked(key, value, expAt, s.sizeOf(key, value), meta, action == computeSet)
+		case computeDelete:
+			if !s.del(key, RemovalDeleted) {
+				action = computeNone
+			}
+		}
+	}()
+	s.notify(removed)
+	return action, expAt
+}
+
+// Expire 修改未过期key的过期时间，expAt < 0 表示不过期，ttl > 0 时记录为续期使用的ttl，滑动过期的key改为固定过期时间
+// key不存在或已过期时返回false
+func (s *shared) Expire(key string, expAt, ttl int64) bool {
+	s.mu.Lock()
+	item, ok := s.live(key)
+	if ok {
+		item.expAt = expAt
+		item.meta.idle = 0
+		item.meta.deadline = 0
+		if ttl > 0 {
+			item.meta.ttl = ttl
 		}
-		//s.count++
+		s.reschedule(key, item.removeAt())
 	}
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
+	return ok
+}
 
+// Touch 按写入时记录的ttl续期，滑动过期的key重新计时，返回新的过期时间，key不存在、已过期或未记录ttl时返回false
+func (s *shared) Touch(key string) (int64, bool) {
+	var expAt int64
+	s.mu.Lock()
+	item, ok := s.live(key)
+	switch {
+	case !ok:
+	case item.meta.idle > 0:
+		atomic.StoreInt64(&item.access, s.clock.Now().UnixNano())
+		expAt = item.expireAt()
+	case item.expAt >= 0 && item.meta.ttl > 0:
+		item.expAt = s.clock.Now().UnixNano() + item.meta.ttl
+		expAt = item.expAt
+	default:
+		ok = false
+	}
+	if ok {
+		s.reschedule(key, item.removeAt())
+	}
+	removed := s.takeRemoved()
 	s.mu.Unlock()
+	s.notify(removed)
+	return expAt, ok
 }
 
 func (s *shared) Del(key string) {
 	s.mu.Lock()
-	s.del(key)
+	s.del(key, RemovalDeleted)
+	removed := s.takeRemoved()
 	s.mu.Unlock()
+	s.notify(removed)
 }
 
+// DelMany 在一次写锁内删除keys中下标为indexes的key
+func (s *shared) DelMany(keys []string, indexes []int) {
+	s.mu.Lock()
+	for _, i := range indexes {
+		s.del(keys[i], RemovalDeleted)
+	}
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
+}
+
+// DelBefore 删除可删除时间不晚于expAt的key，未删除且仍带过期时间的key按当前的可删除时间重新加入expirer
 func (s *shared) DelBefore(expAt int64, keys ...string) {
 	s.mu.Lock()
 	for _, key := range keys {
-		s.delBefore(key, expAt)
+		if !s.delBefore(key, expAt) {
+			if item, ok := s.entries[key]; ok {
+				if e := item.removeAt(); e >= 0 {
+					s.reschedule(key, e)
+				}
+			}
+		}
 	}
+	removed := s.takeRemoved()
 	s.mu.Unlock()
+	s.notify(removed)
 }
 
 func (s *shared) Load(key string, fn LoadFunc) (interface{}, error, bool) {
-	return s.loader.Do(key, fn)
+	val, err, concurrent := s.loader.Do(key, s.observe(fn))
+	if concurrent {
+		atomic.AddUint64(&s.stats.loadDedups, 1)
+	}
+	return val, err, concurrent
+}
+
+// LoadCtx 调用方在ctx结束时返回，fn继续执行
+func (s *shared) LoadCtx(ctx context.Context, key string, fn LoadFunc) (interface{}, error, bool) {
+	val, err, concurrent := s.loader.DoCtx(ctx, key, s.observe(fn))
+	if concurrent {
+		atomic.AddUint64(&s.stats.loadDedups, 1)
+	}
+	return val, err, concurrent
+}
+
+// Refresh key没有正在执行的加载时异步加载，完成后调用handle
+func (s *shared) Refresh(key string, fn LoadFunc, handle func(interface{}, error)) bool {
+	return s.loader.DoAsync(key, s.observe(fn), handle)
+}
+
+// observe 统计加载耗时及结果，panic计为失败
+func (s *shared) observe(fn LoadFunc) LoadFunc {
+	return func() (val interface{}, err error) {
+		start := time.Now()
+		failed := true
+		defer func() {
+			atomic.AddInt64(&s.stats.loadTime, int64(time.Since(start)))
+			if failed {
+				atomic.AddUint64(&s.stats.loadFailures, 1)
+			} else {
+				atomic.AddUint64(&s.stats.loadSuccesses, 1)
+			}
+		}()
+		val, err = fn()
+		failed = err != nil
+		return
+	}
 }
 
 func (s *shared) Scan(handle func(key string, value interface{}, expAt int64)) {
 	s.mu.RLock()
 	for k, v := range s.entries {
-		handle(k, v.value, v.expAt)
+		handle(k, v.value, v.expireAt())
 	}
 	s.mu.RUnlock()
 }
 
-func (s *shared) delBefore(key string, expAt int64) {
+// SampleExpired 从keys中随机检查最多n个带过期时间的key，删除其中已过期的key，返回检查及删除的数量
+// keys需在写锁内维护，只访问抽样的key
+func (s *shared) SampleExpired(keys *keySet, n int, now int64) (sampled, expired int) {
+	s.mu.Lock()
+	for _, key := range keys.Sample(n) {
+		sampled++
+		item, ok := s.entries[key]
+		if !ok { // key已不在分片中
+			keys.Remove(key)
+			continue
+		}
+		if e := item.removeAt(); e < 0 {
+			keys.Remove(key)
+		} else if e <= now && s.del(key, RemovalExpired) {
+			expired++
+		}
+	}
+	removed := s.takeRemoved()
+	s.mu.Unlock()
+	s.notify(removed)
+	return sampled, expired
+}
+
+// live 返回未过期的key，已过期且超过保留时间时删除，需在写锁内调用
+func (s *shared) live(key string) (*entry, bool) {
+	item, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if expAt := item.expireAt(); expAt >= 0 {
+		if now := s.clock.Now().UnixNano(); expAt <= now {
+			s.delBefore(key, now)
+			return nil, false
+		}
+	}
+	return item, true
+}
+
+// delBefore 删除可删除时间不晚于expAt的key，已删除时返回true
+func (s *shared) delBefore(key string, expAt int64) bool {
 	val, ok := s.entries[key]
-	if ok && val.expAt >= 0 && val.expAt <= expAt {
-		s.del(key)
+	if !ok {
+		return false
+	}
+	if e := val.removeAt(); e >= 0 && e <= expAt {
+		return s.del(key, RemovalExpired)
+	}
+	return false
+}
+
+// reschedule 需在写锁内调用，与key的修改一起生效
+func (s *shared) reschedule(key string, expAt int64) {
+	if s.schedule != nil {
+		s.schedule(key, expAt)
+	}
+}
+
+// recordAccess 在读锁内记录访问的key，缓冲区写满时返回true，由调用方加写锁批量更新
+// 缓冲区已满时丢弃本次访问记录
+func (s *shared) recordAccess(key string) bool {
+	pos := atomic.AddInt32(&s.readIdx, 1)
+	if int(pos) > len(s.readBuf) {
+		return false
+	}
+	s.readBuf[pos-1] = key
+	return int(pos) == len(s.readBuf)
+}
+
+// drainAccess 需在写锁内调用
+func (s *shared) drainAccess() {
+	n := int(atomic.LoadInt32(&s.readIdx))
+	if n > len(s.readBuf) {
+		n = len(s.readBuf)
+	}
+	for i := 0; i < n; i++ {
+		s.policy.Access(s.readBuf[i])
+		s.readBuf[i] = _EMPTY_STR
+	}
+	atomic.StoreInt32(&s.readIdx, 0)
+}
+
+// evict 按淘汰策略淘汰key直到不超过最大数量及最大总成本，需在写锁内调用
+func (s *shared) evict() {
+	if !s.overflow() {
+		return
+	}
+	s.drainAccess()
+	if s.maxEntries <= 0 {
+		s.resizePolicy()
+	}
+	for s.overflow() {
+		key, ok := s.policy.Victim()
+		if !ok {
+			return
+		}
+		s.del(key, RemovalEvicted)
+	}
+}
+
+// resizePolicy 只限制总成本时最大key数量未知，按超出总成本前的key数量估算，偏差超过一倍时调整淘汰策略的容量
+func (s *shared) resizePolicy() {
+	n := len(s.entries) - 1
+	if n < 1 {
+		n = 1
+	}
+	if n <= s.policyCap && n >= s.policyCap/2 {
+		return
+	}
+	if r, ok := s.policy.(policyResizer); ok {
+		r.resize(n)
 	}
+	s.policyCap = n
 }
 
-func (s *shared) del(key string) {
+// sizeOf 未限制总成本时为0，缓存的加载错误使用固定成本，不调用Sizer
+func (s *shared) sizeOf(key string, value interface{}) int64 {
+	if s.maxCost <= 0 {
+		return 0
+	}
+	if _, negative := negativeErr(value); negative {
+		return int64(len(key)) + negativeCost
+	}
+	return s.sizer(key, value)
+}
+
+func (s *shared) overflow() bool {
+	return (s.maxEntries > 0 && len(s.entries) > s.maxEntries) ||
+		(s.maxCost > 0 && s.cost > s.maxCost)
+}
+
+// del 删除key，按原因记录统计及待回调的移除记录，key存在时返回true
+func (s *shared) del(key string, reason RemovalReason) bool {
+	item, ok := s.entries[key]
+	if !ok {
+		return false
+	}
+	s.cost -= item.cost
 	delete(s.entries, key)
-	//s.delCalled++
-	//s.count--
+	s.reschedule(key, -1)
+	if s.policy != nil {
+		s.policy.Delete(key)
+	}
+
+	switch reason {
+	case RemovalExpired:
+		atomic.AddUint64(&s.stats.expirations, 1)
+	case RemovalEvicted:
+		atomic.AddUint64(&s.stats.evictions, 1)
+	case RemovalDeleted:
+		atomic.AddUint64(&s.stats.deletes, 1)
+	}
+	s.record(key, item.value, reason)
+	return true
+}
+
+// record 记录待回调的移除记录，缓存的加载错误不回调，需在写锁内调用
+func (s *shared) record(key string, value interface{}, reason RemovalReason) {
+	if s.onRemove == nil {
+		return
+	}
+	if _, negative := negativeErr(value); negative {
+		return
+	}
+	s.removed = append(s.removed, removal{key: key, value: value, reason: reason})
+}
+
+// takeRemoved 取出写锁内记录的移除记录，需在写锁内调用
+func (s *shared) takeRemoved() []removal {
+	if len(s.removed) == 0 {
+		return nil
+	}
+	removed := s.removed
+	s.removed = nil
+	return removed
+}
+
+// notify 在锁外回调
+func (s *shared) notify(removed []removal) {
+	for _, r := range removed {
+		s.onRemove(r.key, r.value, r.reason)
+	}
 }
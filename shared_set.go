@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,34 +14,66 @@ var (
 type sharedSet interface {
 	Index(key string) uint32
 	Get(index uint32, key string) (interface{}, bool)
+	GetMeta(index uint32, key string) (interface{}, int64, entryMeta, bool)
 	GetIgnoreExp(index uint32, key string) (interface{}, int64, bool)
+	Peek(index uint32, key string) (interface{}, int64, bool)
+	GetMany(index uint32, keys []string, indexes []int, values []interface{}, found []bool)
 	Set(index uint32, key string, value interface{})
 	SetEx(index uint32, key string, value interface{}, expAt int64)
+	SetWithCost(index uint32, key string, value interface{}, expAt int64, cost int64)
+	SetWithMeta(index uint32, key string, value interface{}, expAt int64, meta entryMeta)
 	Del(index uint32, key string)
+	SetMany(index uint32, items []setItem, indexes []int)
+	DelMany(index uint32, keys []string, indexes []int)
+	Expire(index uint32, key string, expAt, ttl int64) bool
+	Touch(index uint32, key string) (int64, bool)
+	Compute(index uint32, key string, fn computeFunc) (computeAction, int64)
 	Scan(handle func(key string, value interface{}, expAt int64))
 	Load(index uint32, key string, fn LoadFunc) (interface{}, error, bool)
+	LoadCtx(ctx context.Context, index uint32, key string, fn LoadFunc) (interface{}, error, bool)
+	Refresh(index uint32, key string, fn LoadFunc, handle func(interface{}, error)) bool
+	Close()
+	Closed() bool
+	SharedStats() []Stats
+	ResetStats()
 }
 
 type cache struct {
-	indexFn func(str string, mask uint32) uint32
-	sharers []*shared
-	mask    uint32
+	indexFn    func(str string, mask uint32) uint32
+	sharers    []*shared
+	mask       uint32
+	closed     int32
+	dispatcher *removalDispatcher
+	clock      Clock
+	ownedClock *CoarseClock
 }
 
 type cacheTimer struct {
 	*cache
-	stop   chan struct{}
-	timer  *timer
-	groups [][]string
+	stop    chan struct{}
+	done    chan struct{}
+	expirer expirer
 }
 
-func newCache(sharedNum, sharedCap int) *cache {
+func newCache(sharedNum, sharedCap int, o *options) *cache {
+	var dispatcher *removalDispatcher
+	onRemove := o.listener
+	if onRemove != nil && o.asyncSize > 0 {
+		dispatcher = newRemovalDispatcher(onRemove, o.asyncSize)
+		onRemove = dispatcher.Dispatch
+	}
+
 	if sharedNum <= 1 {
+		s := newShared(sharedCap)
+		s.configure(o, 1, sharedCap, onRemove)
 		return &cache{
 			indexFn: func(str string, mask uint32) uint32 {
 				return 0
 			},
-			sharers: []*shared{newShared(sharedCap)},
+			sharers:    []*shared{s},
+			dispatcher: dispatcher,
+			clock:      o.clock,
+			ownedClock: o.ownedClock,
 		}
 	}
 
@@ -47,34 +81,50 @@ func newCache(sharedNum, sharedCap int) *cache {
 	sharers := make([]*shared, num)
 	for i := 0; i < int(num); i++ {
 		sharers[i] = newShared(sharedCap)
+		sharers[i].configure(o, int(num), sharedCap, onRemove)
 	}
 	return &cache{
 		indexFn: func(str string, mask uint32) uint32 {
 			return fnv32(str) & mask
 		},
-		sharers: sharers,
-		mask:    num - 1,
+		sharers:    sharers,
+		mask:       num - 1,
+		dispatcher: dispatcher,
+		clock:      o.clock,
+		ownedClock: o.ownedClock,
 	}
 }
 
-func newCacheTimer(sharedNum, sharedCap int, cleanInterval time.Duration) *cacheTimer {
-	c := newCache(sharedNum, sharedCap)
+func newCacheTimer(sharedNum, sharedCap int, cleanInterval time.Duration, o *options) *cacheTimer {
+	c := newCache(sharedNum, sharedCap, o)
 	ct := &cacheTimer{
 		cache: c,
 		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
 	}
 
 	realSharedNum := len(c.sharers)
-	if realSharedNum > 1 {
-		ct.groups = make([][]string, realSharedNum)
-		for i := 0; i < realSharedNum; i++ {
-			ct.groups[i] = make([]string, 0, 100)
+	switch o.expiry {
+	case ExpireByHeap:
+		ct.expirer = newHeapExpirer(realSharedNum, c.clock, ct.CleanExpiredKeys)
+	case ExpireBySampling:
+		ct.expirer = newSampleExpirer(c.sharers, cleanInterval, c.clock)
+	default:
+		ct.expirer = newWheelExpirer(realSharedNum, cleanInterval, c.clock, ct.CleanExpiredKeys)
+	}
+	for i, s := range c.sharers {
+		index := uint32(i)
+		s.schedule = func(key string, expAt int64) {
+			if expAt >= 0 {
+				ct.expirer.Add(index, key, expAt)
+			} else {
+				ct.expirer.Remove(index, key)
+			}
 		}
 	}
-
-	ct.timer = newTimer(cleanInterval, time.Now().UnixNano(), ct.CleanExpiredKeys)
 	go func() {
-		ct.timer.Run(ct.stop)
+		defer close(ct.done)
+		ct.expirer.Run(ct.stop)
 	}()
 
 	return ct
@@ -88,10 +138,22 @@ func (c *cache) Get(index uint32, key string) (interface{}, bool) {
 	return c.sharers[index].Get(key)
 }
 
+func (c *cache) GetMeta(index uint32, key string) (interface{}, int64, entryMeta, bool) {
+	return c.sharers[index].GetMeta(key)
+}
+
 func (c *cache) GetIgnoreExp(index uint32, key string) (interface{}, int64, bool) {
 	return c.sharers[index].GetIgnoreExp(key)
 }
 
+func (c *cache) Peek(index uint32, key string) (interface{}, int64, bool) {
+	return c.sharers[index].Peek(key)
+}
+
+func (c *cache) GetMany(index uint32, keys []string, indexes []int, values []interface{}, found []bool) {
+	c.sharers[index].GetMany(keys, indexes, values, found)
+}
+
 func (c *cache) Set(index uint32, key string, value interface{}) {
 	c.sharers[index].Set(key, value, -1)
 }
@@ -100,10 +162,42 @@ func (c *cache) SetEx(index uint32, key string, value interface{}, expAt int64)
 	c.sharers[index].Set(key, value, expAt)
 }
 
+// SetWithCost expAt < 0 表示不过期
+func (c *cache) SetWithCost(index uint32, key string, value interface{}, expAt int64, cost int64) {
+	c.sharers[index].SetWithCost(key, value, expAt, cost)
+}
+
+// SetWithMeta expAt < 0 表示不过期
+func (c *cache) SetWithMeta(index uint32, key string, value interface{}, expAt int64, meta entryMeta) {
+	c.sharers[index].SetWithMeta(key, value, expAt, meta)
+}
+
 func (c *cache) Del(index uint32, key string) {
 	c.sharers[index].Del(key)
 }
 
+// SetMany expAt < 0 表示不过期
+func (c *cache) SetMany(index uint32, items []setItem, indexes []int) {
+	c.sharers[index].SetMany(items, indexes)
+}
+
+func (c *cache) DelMany(index uint32, keys []string, indexes []int) {
+	c.sharers[index].DelMany(keys, indexes)
+}
+
+// Expire expAt < 0 表示不过期
+func (c *cache) Expire(index uint32, key string, expAt, ttl int64) bool {
+	return c.sharers[index].Expire(key, expAt, ttl)
+}
+
+func (c *cache) Touch(index uint32, key string) (int64, bool) {
+	return c.sharers[index].Touch(key)
+}
+
+func (c *cache) Compute(index uint32, key string, fn computeFunc) (computeAction, int64) {
+	return c.sharers[index].Compute(key, fn)
+}
+
 func (c *cache) Scan(handle func(key string, value interface{}, expAt int64)) {
 	for _, s := range c.sharers {
 		s.Scan(handle)
@@ -114,35 +208,61 @@ func (c *cache) Load(index uint32, key string, fn LoadFunc) (interface{}, error,
 	return c.sharers[index].Load(key, fn)
 }
 
-func (ct *cacheTimer) SetEx(index uint32, key string, value interface{}, expAt int64) {
-	ct.sharers[index].Set(key, value, expAt)
-	ct.timer.Add(key, expAt)
+func (c *cache) LoadCtx(ctx context.Context, index uint32, key string, fn LoadFunc) (interface{}, error, bool) {
+	return c.sharers[index].LoadCtx(ctx, key, fn)
+}
+
+func (c *cache) Refresh(index uint32, key string, fn LoadFunc, handle func(interface{}, error)) bool {
+	return c.sharers[index].Refresh(key, fn, handle)
+}
+
+func (c *cache) SharedStats() []Stats {
+	stats := make([]Stats, len(c.sharers))
+	for i, s := range c.sharers {
+		stats[i] = s.stats.snapshot()
+	}
+	return stats
+}
+
+func (c *cache) ResetStats() {
+	for _, s := range c.sharers {
+		s.stats.reset()
+	}
 }
 
-func (ct *cacheTimer) CleanExpiredKeys(unixNano int64, keys []string) {
-	if ct.mask == 0 {
-		ct.sharers[0].DelBefore(time.Now().UnixNano(), keys...)
+func (c *cache) Close() {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
 		return
 	}
+	c.release()
+}
 
-	keysGroup := make(map[uint32][]string, len(ct.sharers))
-	for _, key := range keys {
-		index := ct.indexFn(key, ct.mask)
-		g, ok := keysGroup[index]
-		if !ok {
-			groupL := len(ct.groups)
-			g = ct.groups[groupL-1]
-			ct.groups = ct.groups[:groupL-1]
-		}
-		keysGroup[index] = append(g, key)
+func (c *cache) Closed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+// release 等待异步回调执行完成，之后的回调改为同步调用，并停止由缓存创建的时钟
+func (c *cache) release() {
+	if c.dispatcher != nil {
+		c.dispatcher.Close()
+	}
+	if c.ownedClock != nil {
+		c.ownedClock.Stop()
 	}
+}
 
-	now := time.Now().UnixNano()
-	for i, group := range keysGroup {
-		ct.sharers[i].DelBefore(now, group...)
-		for j := range group {
-			group[j] = _EMPTY_STR
-		}
-		ct.groups = append(ct.groups, group[:0])
+// Close 停止清理过期key的goroutine，并释放expirer记录的key
+func (ct *cacheTimer) Close() {
+	if !atomic.CompareAndSwapInt32(&ct.closed, 0, 1) {
+		return
 	}
+	close(ct.stop)
+	<-ct.done
+	ct.expirer.Release()
+	ct.release()
+}
+
+// CleanExpiredKeys 按expirer的时间删除分片中到期的key，时钟落后时也不会遗漏，未删除的key重新加入expirer
+func (ct *cacheTimer) CleanExpiredKeys(index uint32, unixNano int64, keys []string) {
+	ct.sharers[index].DelBefore(unixNano, keys...)
 }
@@ -115,3 +115,78 @@ func TestShared_Load(t *testing.T) {
 		t.Fatal("concurrent set shared")
 	}
 }
+
+func TestShared_LRU(t *testing.T) {
+	s := newShared(10)
+	s.configure(&options{maxEntries: 3, policy: NewLRUPolicy}, 1, 10, nil)
+	s.Set("t1", 1, -1)
+	s.Set("t2", 2, -1)
+	s.Set("t3", 3, -1)
+
+	if _, ok := s.Get("t1"); !ok {
+		t.Fatal("t1 not found")
+	}
+	s.Set("t4", 4, -1)
+
+	if _, _, ok := s.GetIgnoreExp("t2"); ok {
+		t.Fatal("t2 should be evicted")
+	}
+	for _, key := range []string{"t1", "t3", "t4"} {
+		if _, _, ok := s.GetIgnoreExp(key); !ok {
+			t.Fatal(key, " should exists")
+		}
+	}
+
+	// 读缓冲区写满时批量更新访问顺序
+	for i := 0; i < _readBufSize; i++ {
+		s.Get("t3")
+	}
+	if s.readIdx != 0 {
+		t.Fatal("read buffer should be drained")
+	}
+	s.Set("t5", 5, -1)
+	if _, _, ok := s.GetIgnoreExp("t4"); ok {
+		t.Fatal("t4 should be evicted")
+	}
+	if len(s.entries) != 3 || s.policy.(*lruPolicy).Len() != 3 {
+		t.Fatal("entries should be limited to 3")
+	}
+}
+
+func TestShared_MaxCost(t *testing.T) {
+	s := newShared(10)
+	s.configure(&options{maxCost: 10, policy: NewLRUPolicy, sizer: DefaultSizer}, 1, 10, nil)
+
+	s.SetWithCost("t1", 1, -1, 4)
+	s.SetWithCost("t2", 2, -1, 4)
+	s.SetWithCost("t3", 3, -1, 4)
+	if _, _, ok := s.GetIgnoreExp("t1"); ok {
+		t.Fatal("t1 should be evicted")
+	}
+	if s.cost != 8 {
+		t.Fatal("cost should be 8, got ", s.cost)
+	}
+
+	// 覆盖写入增加成本
+	s.SetWithCost("t2", 2, -1, 9)
+	if _, _, ok := s.GetIgnoreExp("t3"); ok {
+		t.Fatal("t3 should be evicted")
+	}
+	if s.cost != 9 {
+		t.Fatal("cost should be 9, got ", s.cost)
+	}
+
+	// Sizer计算成本: len("t4") + len("abcdef")
+	s.Set("t4", "abcdef", -1)
+	if _, _, ok := s.GetIgnoreExp("t2"); ok {
+		t.Fatal("t2 should be evicted")
+	}
+	if s.cost != 8 {
+		t.Fatal("cost should be 8, got ", s.cost)
+	}
+
+	s.Del("t4")
+	if s.cost != 0 || len(s.entries) != 0 {
+		t.Fatal("cost should be 0, got ", s.cost)
+	}
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -11,9 +12,9 @@ import (
 var _errLoad = errors.New("load func panic")
 
 type call struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{}
+	val  interface{}
+	err  error
 }
 
 type group struct {
@@ -22,21 +23,64 @@ type group struct {
 }
 
 func (g *group) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	c, shared := g.start(key)
+	if shared {
+		<-c.done
+		return c.val, c.err, true
+	}
+
+	g.doCall(c, key, fn)
+	return c.val, c.err, false
+}
+
+// DoCtx 与Do相同，但fn在单独的goroutine中执行，调用方在ctx结束时返回ctx.Err()，fn继续执行直到完成
+func (g *group) DoCtx(ctx context.Context, key string, fn func() (interface{}, error)) (value interface{}, err error,
+	shared bool) {
+	c, shared := g.start(key)
+	if !shared {
+		go g.doCall(c, key, fn)
+	}
+
+	select {
+	case <-c.done:
+		return c.val, c.err, shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), shared
+	}
+}
+
+// DoAsync key没有正在执行的调用时在新的goroutine中执行fn，完成后调用handle，否则直接返回false
+func (g *group) DoAsync(key string, fn func() (interface{}, error), handle func(interface{}, error)) bool {
+	c, shared := g.start(key)
+	if shared {
+		return false
+	}
+
+	go func() {
+		g.doCall(c, key, fn)
+		handle(c.val, c.err)
+	}()
+	return true
+}
+
+// start 返回key正在执行的call，不存在时创建新的call
+func (g *group) start(key string) (*call, bool) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
 	if c, ok := g.m[key]; ok {
 		g.mu.Unlock()
-		c.wg.Wait()
-		return c.val, c.err, true
+		return c, true
 	}
 
-	c := new(call)
-	c.wg.Add(1)
+	c := &call{done: make(chan struct{})}
 	g.m[key] = c
 	g.mu.Unlock()
+	return c, false
+}
 
+func (g *group) doCall(c *call, key string, fn func() (interface{}, error)) {
 	nf := func() (val interface{}, err error) {
 		defer func() {
 			if r := recover(); r != nil {
@@ -57,11 +101,9 @@ func (g *group) Do(key string, fn func() (interface{}, error)) (value interface{
 	}
 
 	c.val, c.err = nf()
-	c.wg.Done()
+	close(c.done)
 
 	g.mu.Lock()
 	delete(g.m, key)
 	g.mu.Unlock()
-
-	return c.val, c.err, false
 }
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats 缓存统计
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Sets          uint64
+	Deletes       uint64
+	Expirations   uint64
+	Evictions     uint64
+	LoadSuccesses uint64
+	LoadFailures  uint64
+	TotalLoadTime time.Duration
+	// LoadDedups 并发加载时等待其它调用结果的次数
+	LoadDedups uint64
+}
+
+// HitRatio 命中率
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+func (s *Stats) add(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Sets += o.Sets
+	s.Deletes += o.Deletes
+	s.Expirations += o.Expirations
+	s.Evictions += o.Evictions
+	s.LoadSuccesses += o.LoadSuccesses
+	s.LoadFailures += o.LoadFailures
+	s.TotalLoadTime += o.TotalLoadTime
+	s.LoadDedups += o.LoadDedups
+}
+
+// sharedStats 分片统计，所有字段原子操作
+type sharedStats struct {
+	hits          uint64
+	misses        uint64
+	sets          uint64
+	deletes       uint64
+	expirations   uint64
+	evictions     uint64
+	loadSuccesses uint64
+	loadFailures  uint64
+	loadTime      int64
+	loadDedups    uint64
+}
+
+func (s *sharedStats) snapshot() Stats {
+	return Stats{
+		Hits:          atomic.LoadUint64(&s.hits),
+		Misses:        atomic.LoadUint64(&s.misses),
+		Sets:          atomic.LoadUint64(&s.sets),
+		Deletes:       atomic.LoadUint64(&s.deletes),
+		Expirations:   atomic.LoadUint64(&s.expirations),
+		Evictions:     atomic.LoadUint64(&s.evictions),
+		LoadSuccesses: atomic.LoadUint64(&s.loadSuccesses),
+		LoadFailures:  atomic.LoadUint64(&s.loadFailures),
+		TotalLoadTime: time.Duration(atomic.LoadInt64(&s.loadTime)),
+		LoadDedups:    atomic.LoadUint64(&s.loadDedups),
+	}
+}
+
+func (s *sharedStats) reset() {
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.sets, 0)
+	atomic.StoreUint64(&s.deletes, 0)
+	atomic.StoreUint64(&s.expirations, 0)
+	atomic.StoreUint64(&s.evictions, 0)
+	atomic.StoreUint64(&s.loadSuccesses, 0)
+	atomic.StoreUint64(&s.loadFailures, 0)
+	atomic.StoreInt64(&s.loadTime, 0)
+	atomic.StoreUint64(&s.loadDedups, 0)
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_Stats(t *testing.T) {
+	cache := NewCache(4, 10, WithMaxEntries(4))
+	cache.Set("t1", 1)
+	cache.SetEx("t2", 2, time.Millisecond)
+	_, _ = cache.Get("t1")
+	_, _ = cache.Get("t3")
+	time.Sleep(2 * time.Millisecond)
+	_, _ = cache.Get("t2")
+	cache.Del("t1")
+	cache.Del("t1")
+
+	_, _ = cache.Load("t4", func() (interface{}, error) {
+		return 4, nil
+	})
+	_, _ = cache.Load("t5", func() (interface{}, error) {
+		return nil, errors.New("load failed")
+	})
+
+	var w sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			<-start
+			_, _ = cache.Load("t6", func() (interface{}, error) {
+				time.Sleep(10 * time.Millisecond)
+				return 6, nil
+			})
+		}()
+	}
+	close(start)
+	w.Wait()
+
+	for i := 0; i < 20; i++ {
+		cache.Set(string(rune('a'+i)), i)
+	}
+
+	stats := cache.Stats()
+	t.Logf("%+v", stats)
+	if stats.Hits != 1 {
+		t.Fatal("hits should be 1, got ", stats.Hits)
+	}
+	// t3、t2、t4、t5及t6的首次读取
+	if stats.Misses < 8 {
+		t.Fatal("misses should be at least 8, got ", stats.Misses)
+	}
+	if stats.Expirations != 1 {
+		t.Fatal("expirations should be 1, got ", stats.Expirations)
+	}
+	if stats.Deletes != 1 {
+		t.Fatal("deletes should be 1, got ", stats.Deletes)
+	}
+	if stats.LoadFailures != 1 {
+		t.Fatal("load failures should be 1, got ", stats.LoadFailures)
+	}
+	if stats.LoadSuccesses+stats.LoadDedups != 6 || stats.LoadSuccesses < 2 {
+		t.Fatal("load successes and dedups mismatch")
+	}
+	if stats.TotalLoadTime < 10*time.Millisecond {
+		t.Fatal("total load time should be at least 10ms, got ", stats.TotalLoadTime)
+	}
+	if stats.Evictions == 0 {
+		t.Fatal("evictions should be recorded")
+	}
+
+	var sum uint64
+	for _, s := range cache.SharedStats() {
+		sum += s.Sets
+	}
+	if sum != stats.Sets {
+		t.Fatal("shared sets should sum to total sets")
+	}
+
+	cache.ResetStats()
+	if cache.Stats() != (Stats{}) {
+		t.Fatal("stats should be reset")
+	}
+}
+
+func TestCache_StatsLoadAsync(t *testing.T) {
+	clock := newManualClock(time.Unix(1000, 0))
+	cache := NewCache(2, 10, WithClock(clock))
+	load := func() (interface{}, error) {
+		return 1, nil
+	}
+	_, _ = cache.LoadAsyncWithEx("t1", load, time.Minute)
+	_, _ = cache.LoadAsyncWithEx("t1", load, time.Minute)
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("should be 1 hit and 1 miss, got %+v", stats)
+	}
+
+	// 返回过期的value记为未命中
+	clock.Set(clock.Now().Add(2 * time.Minute))
+	release := make(chan struct{})
+	defer close(release)
+	if v, _ := cache.LoadAsyncWithEx("t1", func() (interface{}, error) {
+		<-release
+		return 2, nil
+	}, time.Minute); v != 1 {
+		t.Fatal("stale value should be returned, got ", v)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("stale read should be a miss, got %+v", stats)
+	}
+}
@@ -2,138 +2,207 @@ package cache
 
 import (
 	"sync"
-	"sync/atomic"
 	"time"
-	"unsafe"
 )
 
-const (
-	_defSlotNum                = 1 << 5 // 时间轮每一层槽数
-	_overflowTimerTickMultiple = 8      // 下一层时间轮的间隔时间相当于当前轮间隔时间的倍数
-)
+const _defSlotNum = 1 << 5 // 时间轮每一层槽数
 
-// 高层时间轮避免向下级联
+// timer 分层时间轮，上一层的tick为当前层的interval
+// 底层bucket在时间窗口结束时过期，上层bucket在进入时间窗口时将其中的key按过期时间级联到下层，过期精度为底层的tick
+// 每个key最多一个节点，重复Add时移动节点
 type timer struct {
-	curTime       int64
-	tick          int64
-	interval      int64
-	slotNum       int
-	slotMask      int
-	curSlot       int
-	mu            sync.RWMutex
-	slots         []*bucket
-	overflowSet   int32
-	overflowTimer unsafe.Pointer
+	mu            sync.Mutex
+	released      bool
+	nodes         map[string]*timerNode
+	wheels        []*wheel // 下标0为底层
 	expKeysHandle func(int64, []string)
 }
 
-// tick 间隔时间，单位秒
-func newTimer(tick time.Duration, now int64, handle func(int64, []string)) *timer {
+type wheel struct {
+	curTime  int64 // tick的整数倍，底层为已处理到的时间，上层为当前时间窗口的起点
+	tick     int64
+	slotMask int64
+	slots    []*bucket
+}
+
+func newWheel(tick, now int64) *wheel {
 	buckets := make([]*bucket, _defSlotNum)
 	for i := range buckets {
-		buckets[i] = newBucket(_defSlicePool)
+		buckets[i] = newBucket()
+	}
+	return &wheel{
+		curTime:  now - now%tick,
+		tick:     tick,
+		slotMask: _defSlotNum - 1,
+		slots:    buckets,
 	}
+}
+
+// tick 间隔时间，单位秒
+func newTimer(tick time.Duration, now int64, handle func(int64, []string)) *timer {
 	return &timer{
-		curTime:       now,
-		tick:          int64(tick),
-		interval:      (int64(_defSlotNum) - 1) * int64(tick),
-		slotNum:       _defSlotNum,
-		slotMask:      _defSlotNum - 1,
-		slots:         buckets,
+		nodes:         make(map[string]*timerNode),
+		wheels:        []*wheel{newWheel(int64(tick), now)},
 		expKeysHandle: handle,
 	}
 }
 
+// Add 已有节点时移动到新的过期时间，已过期的key在下一个tick删除
 func (t *timer) Add(key string, expAt int64) {
-	t.mu.RLock()
-	delay := expAt - t.curTime
-	if delay <= t.interval { // 加入当前的timer
-		var moveSlot int
-		if delay <= t.tick {
-			moveSlot = 1
-		} else {
-			moveSlot = int(truncate(delay, t.tick))
-		}
-		slot := (t.curSlot + moveSlot) & t.slotMask
-		t.slots[slot].Append(key)
-		t.mu.RUnlock()
-
+	t.mu.Lock()
+	if t.released {
+		t.mu.Unlock()
 		return
 	}
-	t.mu.RUnlock()
-
-	overflowWheel := atomic.LoadPointer(&t.overflowTimer)
-	if overflowWheel == nil {
-		if atomic.CompareAndSwapInt32(&t.overflowSet, 0, 1) { // 设置成功
-			t.mu.RLock()
-			overflowWheel = unsafe.Pointer(newTimer(time.Duration(t.tick)*_overflowTimerTickMultiple, t.curTime,
-				t.expKeysHandle))
-
-			atomic.StorePointer(&t.overflowTimer, overflowWheel)
-			t.mu.RUnlock()
-		} else {
-			for {
-				time.Sleep(5 * time.Millisecond)
-				overflowWheel = atomic.LoadPointer(&t.overflowTimer)
-				if overflowWheel != nil {
-					break
-				}
-			}
+	n, ok := t.nodes[key]
+	if ok {
+		n.bucket.Remove(n)
+	} else {
+		n = &timerNode{key: key}
+		t.nodes[key] = n
+	}
+	n.expAt = expAt
+	if !t.place(n) {
+		w := t.wheels[0]
+		w.slots[w.curTime/w.tick&w.slotMask].Push(n)
+	}
+	t.mu.Unlock()
+}
+
+// Remove 删除key的节点
+func (t *timer) Remove(key string) {
+	t.mu.Lock()
+	if n, ok := t.nodes[key]; ok {
+		n.bucket.Remove(n)
+		delete(t.nodes, key)
+	}
+	t.mu.Unlock()
+}
+
+// place 按过期时间加入对应层的bucket，在底层已过期时返回false，需在锁内调用
+// 上层的当前时间窗口已级联到下层，下层放不下的节点一定在上层之后的时间窗口
+func (t *timer) place(n *timerNode) bool {
+	for i := 0; ; i++ {
+		if i == len(t.wheels) {
+			lower := t.wheels[i-1]
+			t.wheels = append(t.wheels, newWheel(lower.tick*_defSlotNum, lower.curTime))
+		}
+		w := t.wheels[i]
+		vid, cur := n.expAt/w.tick, w.curTime/w.tick
+		if i == 0 && vid < cur {
+			return false
+		}
+		if vid-cur < _defSlotNum {
+			w.slots[vid&w.slotMask].Push(n)
+			return true
 		}
 	}
-	(*timer)(overflowWheel).Add(key, expAt)
 }
 
+// advanceClock 推进到now，ticker延迟时一次推进多个tick，跳过其中没有节点需要处理的tick
 func (t *timer) advanceClock(now int64) {
-	var (
-		curSlot   int
-		interval  int64
-		nextTimer *timer
-	)
+	var keys []string
+	expire := func(n *timerNode) {
+		delete(t.nodes, n.key)
+		keys = append(keys, n.key)
+	}
+	cascade := func(n *timerNode) {
+		if !t.place(n) {
+			expire(n)
+		}
+	}
 
 	t.mu.Lock()
-	t.curSlot++
-	if t.curSlot > t.slotMask {
-		t.curSlot = 0
+	if t.released {
+		t.mu.Unlock()
+		return
 	}
-	t.curTime = now
-	curSlot = t.curSlot
-
-	if t.overflowTimer != nil {
-		nextTimer = (*timer)(t.overflowTimer)
-		interval = now - nextTimer.curTime
+	base := t.wheels[0]
+	for base.curTime+base.tick <= now {
+		if base.curTime+2*base.tick <= now {
+			t.skip(now)
+		}
+		slot := base.curTime / base.tick & base.slotMask
+		base.curTime += base.tick
+		base.slots[slot].Export(expire)
+
+		for i := 1; i < len(t.wheels); i++ {
+			w := t.wheels[i]
+			if w.curTime+w.tick > base.curTime {
+				break
+			}
+			w.curTime += w.tick
+			w.slots[w.curTime/w.tick&w.slotMask].Export(cascade)
+		}
 	}
 	t.mu.Unlock()
 
-	// ticker获取的时间不一定是精准的t.tick的_overflowTimerTickMultiple倍数
-	if interval > (int64(_overflowTimerTickMultiple)*t.tick - t.tick/2) {
-		nextTimer.advanceClock(now)
+	if len(keys) > 0 {
+		t.expKeysHandle(now, keys)
 	}
-
-	t.scan(curSlot, now)
 }
 
-func (t *timer) scan(slot int, now int64) {
-	keys := t.slots[slot].ExportKeys()
-	if len(keys) > 0 {
-		t.expKeysHandle(now, keys)
+// skip 所有层跳到下一个有节点需要处理的tick，最多跳到now的前一个tick，需在锁内调用
+// 跳过的时间窗口中没有节点，各层的curTime按tick对齐到底层的curTime
+func (t *timer) skip(now int64) {
+	base := t.wheels[0]
+	to := now - now%base.tick - base.tick
+	for i, w := range t.wheels {
+		cur := w.curTime / w.tick
+		first := cur
+		if i > 0 { // 上层当前时间窗口已级联到下层
+			first++
+		}
+		for vid := first; vid < cur+_defSlotNum; vid++ {
+			if w.slots[vid&w.slotMask].Empty() {
+				continue
+			}
+			// 底层在推进前的curTime为vid*tick时过期，上层在底层推进到vid*tick时级联
+			at := vid * w.tick
+			if i > 0 {
+				at -= base.tick
+			}
+			if at < to {
+				to = at
+			}
+			break
+		}
+	}
+	if to <= base.curTime {
+		return
+	}
+	for _, w := range t.wheels {
+		w.curTime = to - to%w.tick
 	}
 }
 
-func (t *timer) Run(stop <-chan struct{}) {
-	ticker := time.NewTicker(time.Duration(t.tick))
+// Run ticker的间隔应与tick一致，不晚于已处理时间的tick不推进
+func (t *timer) Run(ticker Ticker, stop <-chan struct{}) {
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-stop:
 			return
-		case now := <-ticker.C:
+		case now := <-ticker.C():
 			t.advanceClock(now.UnixNano())
 		}
 	}
 }
 
-func truncate(x, m int64) int64 {
-	return x/m + x%m&1
+// Release 释放时间轮，之后的Add不再生效
+// 调用前需保证Run已经退出
+func (t *timer) Release() {
+	t.mu.Lock()
+	t.released = true
+	t.nodes = nil
+	t.wheels = nil
+	t.mu.Unlock()
+}
+
+// Len 时间轮中的节点数
+func (t *timer) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.nodes)
 }
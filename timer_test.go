@@ -2,6 +2,7 @@ package cache
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -13,7 +14,7 @@ func TestTimer_Add(t *testing.T) {
 		fmt.Println("-----")
 	})
 	go func() {
-		timer.Run(make(chan struct{}))
+		timer.Run(_realClock.NewTicker(time.Millisecond), make(chan struct{}))
 	}()
 
 	timer.Add("t1", now.Add(time.Millisecond).UnixNano())
@@ -25,3 +26,206 @@ func TestTimer_Add(t *testing.T) {
 	timer.Add("t7", now.Add(49*time.Millisecond).UnixNano())
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestTimer_Cascade(t *testing.T) {
+	tick := int64(time.Second)
+	start := time.Unix(1000, 300).UnixNano()
+	ttls := map[string]time.Duration{
+		"t1": 1500 * time.Millisecond,
+		"t2": 40 * time.Second,
+		"t3": 20*time.Minute + 7*time.Second,
+		"t4": 3*time.Hour + 500*time.Millisecond,
+		"t5": 26 * time.Hour,
+		"t6": 3*24*time.Hour + 7*time.Second,
+	}
+
+	expired := make(map[string]int64)
+	timer := newTimer(time.Second, start, func(now int64, keys []string) {
+		for _, key := range keys {
+			expired[key] = now
+		}
+	})
+	for key, ttl := range ttls {
+		timer.Add(key, start+int64(ttl))
+	}
+
+	end := start + int64(3*24*time.Hour+time.Minute)
+	for now := start + tick; now <= end; now += tick {
+		timer.advanceClock(now)
+	}
+
+	for key, ttl := range ttls {
+		expAt := start + int64(ttl)
+		at, ok := expired[key]
+		if !ok {
+			t.Fatalf("%s should be expired", key)
+		}
+		// 在过期后的一个tick内删除
+		if at < expAt || at-expAt > tick {
+			t.Fatalf("%s expired at %d, expAt %d", key, at, expAt)
+		}
+	}
+}
+
+func TestTimer_Skip(t *testing.T) {
+	tick := int64(time.Millisecond)
+	start := time.Unix(1000, 300).UnixNano()
+	ttls := map[string]time.Duration{
+		"t1": 1500 * time.Microsecond,
+		"t2": 40 * time.Second,
+		"t3": 20*time.Minute + 7*time.Millisecond,
+		"t4": 3 * time.Hour,
+		"t5": 26*time.Hour + 500*time.Microsecond,
+		"t6": 3*24*time.Hour + 7*time.Second,
+	}
+
+	expired := make(map[string]int64)
+	timer := newTimer(time.Millisecond, start, func(now int64, keys []string) {
+		for _, key := range keys {
+			expired[key] = now
+		}
+	})
+	for key, ttl := range ttls {
+		timer.Add(key, start+int64(ttl))
+	}
+
+	// 落后很多tick时跳过没有节点的tick，每次推进都在过期后的第一次推进时删除
+	steps := []time.Duration{time.Millisecond, 10 * time.Second, time.Hour, 25 * time.Hour, 30 * 24 * time.Hour}
+	var prev int64
+	for _, step := range steps {
+		now := start + int64(step)
+		timer.advanceClock(now)
+		for key, ttl := range ttls {
+			due := (start+int64(ttl))/tick*tick + tick
+			at, ok := expired[key]
+			if due > now {
+				if ok {
+					t.Fatalf("%s should not be expired at %s", key, step)
+				}
+				continue
+			}
+			if due > prev && at != now {
+				t.Fatalf("%s should be expired at %s", key, step)
+			}
+		}
+		prev = now
+	}
+	if timer.Len() != 0 {
+		t.Fatal("timer should be empty")
+	}
+
+	// 跳过后加入的key按tick过期
+	now := start + int64(30*24*time.Hour)
+	timer.Add("t7", now+int64(50*time.Millisecond))
+	for i := 0; i < 50; i++ {
+		now += tick
+		timer.advanceClock(now)
+	}
+	if _, ok := expired["t7"]; ok {
+		t.Fatal("t7 should not be expired")
+	}
+	timer.advanceClock(now + tick)
+	if _, ok := expired["t7"]; !ok {
+		t.Fatal("t7 should be expired")
+	}
+}
+
+func TestTimer_Dedup(t *testing.T) {
+	start := time.Unix(1000, 0).UnixNano()
+	expired := make(map[string]int)
+	timer := newTimer(time.Second, start, func(now int64, keys []string) {
+		for _, key := range keys {
+			expired[key]++
+		}
+	})
+
+	for i := 1; i <= 100; i++ {
+		timer.Add("t1", start+int64(i)*int64(time.Second))
+	}
+	timer.Add("t2", start+int64(time.Second))
+	timer.Add("t3", start+int64(time.Hour))
+	timer.Remove("t2")
+	timer.Remove("t4")
+	if timer.Len() != 2 {
+		t.Fatal("timer should have 2 nodes, got ", timer.Len())
+	}
+
+	// 移动后的节点只在最后的过期时间触发
+	timer.advanceClock(start + int64(99*time.Second))
+	if len(expired) != 0 {
+		t.Fatal("no key should be expired: ", expired)
+	}
+	timer.advanceClock(start + int64(101*time.Second))
+	if expired["t1"] != 1 || len(expired) != 1 {
+		t.Fatal("t1 should be expired once: ", expired)
+	}
+	if timer.Len() != 1 {
+		t.Fatal("timer should have 1 node, got ", timer.Len())
+	}
+
+	timer.Release()
+	timer.Add("t5", start+int64(200*time.Second))
+	if timer.Len() != 0 {
+		t.Fatal("released timer should be empty")
+	}
+}
+
+func TestCacheTimer_Dedup(t *testing.T) {
+	c := NewCacheWithGC(2, 10, time.Minute)
+	defer c.Close()
+	ct := c.s.(*cacheTimer)
+
+	for i := 0; i < 100; i++ {
+		c.SetEx("t1", i, time.Hour)
+		c.SetEx("t2", i, time.Duration(i)*time.Second)
+	}
+	c.SetEx("t3", 1, time.Hour)
+	if n := ct.expirer.(*wheelExpirer).Len(); n != 3 {
+		t.Fatal("timer should have 3 nodes, got ", n)
+	}
+
+	c.Set("t1", 1)
+	c.Del("t2")
+	c.SetWithCost("t3", 1, 1)
+	if n := ct.expirer.(*wheelExpirer).Len(); n != 0 {
+		t.Fatal("timer should be empty, got ", n)
+	}
+}
+
+func TestCacheTimer_ConcurrentSchedule(t *testing.T) {
+	c := NewCacheWithGC(2, 10, time.Minute)
+	defer c.Close()
+	timer := c.s.(*cacheTimer).expirer.(*wheelExpirer)
+
+	keys := []string{"t1", "t2", "t3", "t4"}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := keys[(g+i)%len(keys)]
+				switch (g + i) % 3 {
+				case 0:
+					c.SetEx(key, i, time.Hour)
+				case 1:
+					c.Set(key, i)
+				default:
+					c.Del(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// 写入与expirer的修改在同一次写锁内完成，时间轮中只有带过期时间的key
+	var ttlKeys int
+	c.s.Scan(func(key string, value interface{}, expAt int64) {
+		if expAt >= 0 {
+			ttlKeys++
+		}
+	})
+	if n := timer.Len(); n != ttlKeys {
+		t.Fatalf("timer should have %d nodes, got %d", ttlKeys, n)
+	}
+}
@@ -0,0 +1,221 @@
+package cache
+
+const (
+	_sketchDepth        = 4
+	_sketchMaxCount     = 15 // 计数器上限
+	_sketchSampleFactor = 10 // 累计写入容量的倍数后计数衰减一半
+	_tinyLFUWindowRatio = 1  // window队列占容量的百分比
+)
+
+var (
+	_ EvictionPolicy = (*tinyLFUPolicy)(nil)
+	_ policyResizer  = (*tinyLFUPolicy)(nil)
+)
+
+// tinyLFUPolicy W-TinyLFU淘汰策略
+// 新key先进入window LRU，window满时window的淘汰候选与main LRU的淘汰候选比较访问频率，
+// 只有候选频率更高时才替换main中的key，否则淘汰候选
+type tinyLFUPolicy struct {
+	window    *lruPolicy
+	main      *lruPolicy
+	sketch    *cmSketch
+	windowCap int
+	mainCap   int
+}
+
+func NewTinyLFUPolicy(capacity int) EvictionPolicy {
+	windowCap, mainCap := tinyLFUCaps(capacity)
+	return &tinyLFUPolicy{
+		window:    newLRU(windowCap),
+		main:      newLRU(mainCap),
+		sketch:    newCMSketch(capacity),
+		windowCap: windowCap,
+		mainCap:   mainCap,
+	}
+}
+
+func tinyLFUCaps(capacity int) (windowCap, mainCap int) {
+	windowCap = capacity * _tinyLFUWindowRatio / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap = capacity - windowCap
+	if mainCap < 1 {
+		mainCap = 1
+	}
+	return windowCap, mainCap
+}
+
+// resize 调整window及main的容量，sketch宽度不足时重建
+func (p *tinyLFUPolicy) resize(capacity int) {
+	p.windowCap, p.mainCap = tinyLFUCaps(capacity)
+	if nextPow2(uint64(capacity)) > p.sketch.mask+1 {
+		p.sketch = newCMSketch(capacity)
+	}
+}
+
+func (p *tinyLFUPolicy) Access(key string) {
+	p.sketch.Increment(key)
+	if _, ok := p.window.nodes[key]; ok {
+		p.window.Access(key)
+		return
+	}
+	p.main.Access(key)
+}
+
+func (p *tinyLFUPolicy) Insert(key string) {
+	if _, ok := p.main.nodes[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.sketch.Increment(key)
+	p.window.Insert(key)
+}
+
+func (p *tinyLFUPolicy) Delete(key string) {
+	p.window.Delete(key)
+	p.main.Delete(key)
+}
+
+func (p *tinyLFUPolicy) Victim() (string, bool) {
+	for p.window.Len() > p.windowCap {
+		candidate, _ := p.window.Victim()
+		if p.main.Len() < p.mainCap {
+			p.main.Insert(candidate)
+			continue
+		}
+
+		victim := p.main.list.back()
+		if victim == nil {
+			return candidate, true
+		}
+		// 准入过滤：候选频率更高才替换
+		if p.sketch.Estimate(candidate) > p.sketch.Estimate(victim.key) {
+			p.main.Delete(victim.key)
+			p.main.Insert(candidate)
+			return victim.key, true
+		}
+		return candidate, true
+	}
+
+	if key, ok := p.main.Victim(); ok {
+		return key, true
+	}
+	return p.window.Victim()
+}
+
+// cmSketch 4位计数的count-min sketch，前置doorkeeper布隆过滤器过滤只访问一次的key，
+// 写入次数达到阈值后所有计数减半，doorkeeper清空
+type cmSketch struct {
+	rows       [_sketchDepth][]uint8
+	mask       uint64
+	door       []uint64
+	doorMask   uint64
+	additions  int
+	sampleSize int
+}
+
+func newCMSketch(capacity int) *cmSketch {
+	width := nextPow2(uint64(capacity))
+	if width < 16 {
+		width = 16
+	}
+	s := &cmSketch{
+		mask:       width - 1,
+		door:       make([]uint64, width/8),
+		doorMask:   width*8 - 1,
+		sampleSize: _sketchSampleFactor * capacity,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *cmSketch) Increment(key string) {
+	h1, h2 := hash64(key)
+	if !s.doorkeeper(h1, h2) {
+		return
+	}
+
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < _sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *cmSketch) Estimate(key string) int {
+	h1, h2 := hash64(key)
+	min := uint8(_sketchMaxCount)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < min {
+			min = s.rows[i][idx]
+		}
+	}
+	if s.inDoor(h1, h2) {
+		return int(min) + 1
+	}
+	return int(min)
+}
+
+// doorkeeper key已在doorkeeper中时返回true，否则加入doorkeeper并返回false
+func (s *cmSketch) doorkeeper(h1, h2 uint64) bool {
+	if s.inDoor(h1, h2) {
+		return true
+	}
+	b1, b2 := h1&s.doorMask, h2&s.doorMask
+	s.door[b1>>6] |= 1 << (b1 & 63)
+	s.door[b2>>6] |= 1 << (b2 & 63)
+	return false
+}
+
+func (s *cmSketch) inDoor(h1, h2 uint64) bool {
+	b1, b2 := h1&s.doorMask, h2&s.doorMask
+	return s.door[b1>>6]&(1<<(b1&63)) != 0 && s.door[b2>>6]&(1<<(b2&63)) != 0
+}
+
+// reset 计数衰减
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		row := s.rows[i]
+		for j := range row {
+			row[j] >>= 1
+		}
+	}
+	for i := range s.door {
+		s.door[i] = 0
+	}
+	s.additions = 0
+}
+
+// hash64 fnv64a，拆分为两个哈希值用于双重哈希
+func hash64(str string) (uint64, uint64) {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(str); i++ {
+		hash ^= uint64(str[i])
+		hash *= 1099511628211
+	}
+	return hash, hash>>32 | hash<<32 | 1
+}
+
+func nextPow2(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	n--
+	n |= n >> 1
+	n |= n >> 2
+	n |= n >> 4
+	n |= n >> 8
+	n |= n >> 16
+	n |= n >> 32
+	return n + 1
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCMSketch(t *testing.T) {
+	s := newCMSketch(100)
+
+	s.Increment("t1")
+	if n := s.Estimate("t1"); n != 1 {
+		t.Fatal("t1 should only in doorkeeper, estimate: ", n)
+	}
+	for i := 0; i < 5; i++ {
+		s.Increment("t1")
+	}
+	if n := s.Estimate("t1"); n != 6 {
+		t.Fatal("t1 estimate should be 6, got ", n)
+	}
+	for i := 0; i < 20; i++ {
+		s.Increment("t1")
+	}
+	if n := s.Estimate("t1"); n != _sketchMaxCount+1 {
+		t.Fatal("t1 estimate should be saturated, got ", n)
+	}
+	if n := s.Estimate("t2"); n != 0 {
+		t.Fatal("t2 estimate should be 0, got ", n)
+	}
+
+	// 写入达到阈值后计数减半
+	for i := 0; s.additions > 0; i++ {
+		key := strconv.Itoa(i)
+		s.Increment(key)
+		s.Increment(key)
+	}
+	if n := s.Estimate("t1"); n > _sketchMaxCount/2 {
+		t.Fatal("t1 estimate should be halved, got ", n)
+	}
+}
+
+func TestTinyLFUPolicy(t *testing.T) {
+	p := NewTinyLFUPolicy(4).(*tinyLFUPolicy)
+	for _, key := range []string{"t1", "t2", "t3", "t4"} {
+		p.Insert(key)
+	}
+	for i := 0; i < 3; i++ {
+		p.Access("t1")
+		p.Access("t2")
+		p.Access("t3")
+	}
+
+	// window中的t4频率低于main中最久未访问的key，直接淘汰
+	p.Insert("t5")
+	key, _ := p.Victim()
+	if key != "t4" {
+		t.Fatal("victim should be t4, got ", key)
+	}
+
+	// t6频率更高，替换main中最久未访问的t1
+	for i := 0; i < 10; i++ {
+		p.sketch.Increment("t6")
+	}
+	p.Insert("t6")
+	key, _ = p.Victim()
+	if key != "t5" {
+		t.Fatal("victim should be t5, got ", key)
+	}
+	p.Insert("t7")
+	key, _ = p.Victim()
+	if key != "t1" {
+		t.Fatal("victim should be t1, got ", key)
+	}
+	if _, ok := p.main.nodes["t6"]; !ok {
+		t.Fatal("t6 should be admitted to main")
+	}
+}
+
+// TestTinyLFU_HitRatio 按访问序列对比W-TinyLFU和LRU的命中率
+func TestTinyLFU_HitRatio(t *testing.T) {
+	traces := []struct {
+		name  string
+		trace []string
+	}{
+		{"zipf", zipfTrace(_traceLen, 2)},
+		{"scan", scanTrace(_traceLen, 2)},
+	}
+	for _, tr := range traces {
+		lru := NewCache(1, _traceCapacity, WithMaxEntries(_traceCapacity), WithEvictionPolicy(NewLRUPolicy))
+		tinyLFU := NewCache(1, _traceCapacity, WithMaxEntries(_traceCapacity), WithEvictionPolicy(NewTinyLFUPolicy))
+		lruRatio := hitRatio(lru, tr.trace, len(tr.trace))
+		tinyLFURatio := hitRatio(tinyLFU, tr.trace, len(tr.trace))
+		t.Logf("%s: lru %.4f, tinylfu %.4f", tr.name, lruRatio, tinyLFURatio)
+		if tinyLFURatio < lruRatio {
+			t.Fatalf("%s: tinylfu hit ratio %.4f should not be lower than lru %.4f", tr.name, tinyLFURatio, lruRatio)
+		}
+	}
+}
+
+// TestEvictionPolicy_CostHitRatio 只限制总成本时淘汰策略按估算的容量调整，命中率接近按数量限制
+func TestEvictionPolicy_CostHitRatio(t *testing.T) {
+	unit := func(key string, value interface{}) int64 {
+		return 1
+	}
+	trace := scanTrace(_traceLen, 2)
+	lru := NewCache(1, 0, WithMaxCost(_traceCapacity), WithSizer(unit), WithEvictionPolicy(NewLRUPolicy))
+	lruRatio := hitRatio(lru, trace, len(trace))
+	for _, p := range []struct {
+		name    string
+		factory PolicyFactory
+	}{
+		{"s3fifo", NewS3FIFOPolicy},
+		{"tinylfu", NewTinyLFUPolicy},
+	} {
+		byEntries := NewCache(1, _traceCapacity, WithMaxEntries(_traceCapacity), WithEvictionPolicy(p.factory))
+		byCost := NewCache(1, 0, WithMaxCost(_traceCapacity), WithSizer(unit), WithEvictionPolicy(p.factory))
+		entriesRatio := hitRatio(byEntries, trace, len(trace))
+		costRatio := hitRatio(byCost, trace, len(trace))
+		t.Logf("%s: lru %.4f, entries %.4f, cost %.4f", p.name, lruRatio, entriesRatio, costRatio)
+		if costRatio < entriesRatio*0.95 || costRatio <= lruRatio {
+			t.Fatalf("%s: cost hit ratio %.4f should be close to entries %.4f and above lru %.4f",
+				p.name, costRatio, entriesRatio, lruRatio)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ErrTypeMismatch TypedCache读取到的value不是V类型，如通过Cache写入了相同的key
+var ErrTypeMismatch = errors.New("cache value type mismatch")
+
+// KeyHasher 将key转换为缓存内部使用的字符串，不同的key必须转换为不同的字符串
+type KeyHasher[K comparable] func(key K) string
+
+type TypedLoadFunc[V any] func() (V, error)
+
+// TypedCache 类型安全的缓存，基于Cache的分片及时间轮实现
+type TypedCache[K comparable, V any] struct {
+	c    *Cache
+	hash KeyHasher[K]
+}
+
+// NewTypedCache hasher为nil时使用DefaultKeyHasher
+func NewTypedCache[K comparable, V any](sharedNum, sharedCap int, hasher KeyHasher[K], opts ...Option) *TypedCache[K, V] {
+	return newTypedCache[K, V](NewCache(sharedNum, sharedCap, opts...), hasher)
+}
+
+func NewTypedCacheWithGC[K comparable, V any](sharedNum, sharedCap int, gcInterval time.Duration, hasher KeyHasher[K],
+	opts ...Option) *TypedCache[K, V] {
+	return newTypedCache[K, V](NewCacheWithGC(sharedNum, sharedCap, gcInterval, opts...), hasher)
+}
+
+func newTypedCache[K comparable, V any](c *Cache, hasher KeyHasher[K]) *TypedCache[K, V] {
+	if hasher == nil {
+		hasher = DefaultKeyHasher[K]
+	}
+	return &TypedCache[K, V]{
+		c:    c,
+		hash: hasher,
+	}
+}
+
+// DefaultKeyHasher 字符串及整数key直接转换，指针及channel按地址转换，其它类型使用带类型前缀的%#v，保证不同的key转换为不同的字符串
+// K为接口类型时（go 1.20及以上）所有key都带类型前缀，避免不同类型的key冲突，如"1"与1
+func DefaultKeyHasher[K comparable](key K) string {
+	var zero K
+	if any(zero) == nil {
+		return formatKey(key)
+	}
+	switch k := any(key).(type) {
+	case string:
+		return k
+	case int:
+		return strconv.Itoa(k)
+	case int64:
+		return strconv.FormatInt(k, 10)
+	case int32:
+		return strconv.FormatInt(int64(k), 10)
+	case uint64:
+		return strconv.FormatUint(k, 10)
+	case uint32:
+		return strconv.FormatUint(uint64(k), 10)
+	case uint:
+		return strconv.FormatUint(uint64(k), 10)
+	default:
+		return formatKey(key)
+	}
+}
+
+// formatKey 指针及channel相等时地址相同，%#v会打印指针指向的值，改为按地址转换，嵌套的指针%#v按地址打印
+func formatKey(key interface{}) string {
+	switch reflect.ValueOf(key).Kind() {
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Sprintf("%T:%p", key, key)
+	default:
+		return fmt.Sprintf("%T:%#v", key, key)
+	}
+}
+
+func (t *TypedCache[K, V]) Get(key K) (V, error) {
+	value, err := t.c.Get(t.hash(key))
+	return t.typed(value, err)
+}
+
+func (t *TypedCache[K, V]) Set(key K, value V) {
+	t.c.Set(t.hash(key), value)
+}
+
+func (t *TypedCache[K, V]) SetEx(key K, value V, ttl time.Duration) {
+	t.c.SetEx(t.hash(key), value, ttl)
+}
+
+func (t *TypedCache[K, V]) Del(key K) {
+	t.c.Del(t.hash(key))
+}
+
+func (t *TypedCache[K, V]) Load(key K, fn TypedLoadFunc[V]) (V, error) {
+	return t.typed(t.c.Load(t.hash(key), t.loadFunc(fn)))
+}
+
+func (t *TypedCache[K, V]) LoadWithEx(key K, fn TypedLoadFunc[V], ttl time.Duration, opts ...LoadOption) (V, error) {
+	return t.typed(t.c.LoadWithEx(t.hash(key), t.loadFunc(fn), ttl, opts...))
+}
+
+func (t *TypedCache[K, V]) LoadAsyncWithEx(key K, fn TypedLoadFunc[V], ttl time.Duration) (V, error) {
+	return t.typed(t.c.LoadAsyncWithEx(t.hash(key), t.loadFunc(fn), ttl))
+}
+
+func (t *TypedCache[K, V]) LoadAsyncWithStale(key K, fn TypedLoadFunc[V], ttl, maxStale time.Duration) (V, error) {
+	return t.typed(t.c.LoadAsyncWithStale(t.hash(key), t.loadFunc(fn), ttl, maxStale))
+}
+
+func (t *TypedCache[K, V]) Close() {
+	t.c.Close()
+}
+
+func (t *TypedCache[K, V]) Closed() bool {
+	return t.c.Closed()
+}
+
+func (t *TypedCache[K, V]) loadFunc(fn TypedLoadFunc[V]) LoadFunc {
+	return func() (interface{}, error) {
+		return fn()
+	}
+}
+
+// typed value不是V类型时返回ErrTypeMismatch，V为接口类型时nil返回零值
+func (t *TypedCache[K, V]) typed(value interface{}, err error) (V, error) {
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	v, ok := value.(V)
+	if !ok && (value != nil || any(v) != nil) {
+		return v, fmt.Errorf("%w: %T", ErrTypeMismatch, value)
+	}
+	return v, nil
+}
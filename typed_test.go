@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type typedKey struct {
+	tenant string
+	id     int
+}
+
+func TestTypedCache(t *testing.T) {
+	cache := NewTypedCacheWithGC[int, string](2, 50, time.Millisecond, nil)
+	defer cache.Close()
+
+	cache.Set(1, "t1")
+	val, err := cache.Get(1)
+	if err != nil || val != "t1" {
+		t.Fatal("1 should be t1")
+	}
+
+	cache.SetEx(2, "t2", 2*time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cache.Get(2); !ErrIsNotFound(err) {
+		t.Fatal("2 should be expired")
+	}
+
+	cache.Del(1)
+	if val, err := cache.Get(1); !ErrIsNotFound(err) || val != "" {
+		t.Fatal("1 should be deleted")
+	}
+}
+
+func TestTypedCache_Load(t *testing.T) {
+	cache := NewTypedCache[typedKey, int](2, 50, func(key typedKey) string {
+		return key.tenant + ":" + strconv.Itoa(key.id)
+	})
+
+	key := typedKey{tenant: "a", id: 1}
+	val, err := cache.Load(key, func() (int, error) {
+		return 10, nil
+	})
+	if err != nil || val != 10 {
+		t.Fatal("load value should be 10")
+	}
+	val, err = cache.LoadWithEx(key, func() (int, error) {
+		return 20, nil
+	}, time.Second)
+	if err != nil || val != 10 {
+		t.Fatal("value should be cached")
+	}
+	if _, err := cache.Get(typedKey{tenant: "b", id: 1}); !ErrIsNotFound(err) {
+		t.Fatal("b:1 should not exists")
+	}
+
+	loadErr := errors.New("load failed")
+	_, err = cache.LoadAsyncWithEx(typedKey{tenant: "a", id: 2}, func() (int, error) {
+		return 0, loadErr
+	}, time.Second)
+	if !errors.Is(err, loadErr) {
+		t.Fatal("load error should be returned")
+	}
+}
+
+func TestDefaultKeyHasher(t *testing.T) {
+	if DefaultKeyHasher("t1") != "t1" {
+		t.Fatal("string key should not be changed")
+	}
+	if DefaultKeyHasher(int64(-12)) != "-12" {
+		t.Fatal("int64 key should be formatted")
+	}
+	if DefaultKeyHasher(typedKey{tenant: "a", id: 1}) == DefaultKeyHasher(typedKey{tenant: "a", id: 2}) {
+		t.Fatal("different struct keys should not collide")
+	}
+	if DefaultKeyHasher([2]string{"a b", "c"}) == DefaultKeyHasher([2]string{"a", "b c"}) {
+		t.Fatal("different array keys should not collide")
+	}
+	if DefaultKeyHasher(typedKey{tenant: "a 1"}) == DefaultKeyHasher(typedKey{tenant: "a", id: 1}) {
+		t.Fatal("struct keys with spaces should not collide")
+	}
+}
+
+func TestTypedCache_PointerKey(t *testing.T) {
+	a, b := &typedKey{tenant: "a", id: 1}, &typedKey{tenant: "a", id: 1}
+	if DefaultKeyHasher(a) == DefaultKeyHasher(b) {
+		t.Fatal("different pointers with equal content should not collide")
+	}
+	if DefaultKeyHasher(typedKey{tenant: "a"}) != DefaultKeyHasher(typedKey{tenant: "a", id: 0}) {
+		t.Fatal("equal struct keys should have the same hash")
+	}
+
+	cache := NewTypedCache[*typedKey, int](2, 50, nil)
+	cache.Set(a, 1)
+	cache.Set(b, 2)
+	if v, err := cache.Get(a); err != nil || v != 1 {
+		t.Fatal("a should be 1, got ", v, err)
+	}
+	if v, err := cache.Get(b); err != nil || v != 2 {
+		t.Fatal("b should be 2, got ", v, err)
+	}
+
+	type nested struct{ k *typedKey }
+	if DefaultKeyHasher(nested{a}) == DefaultKeyHasher(nested{b}) {
+		t.Fatal("nested pointers with equal content should not collide")
+	}
+	if DefaultKeyHasher(nested{a}) != DefaultKeyHasher(nested{a}) {
+		t.Fatal("nested pointers should be hashed by address")
+	}
+}
+
+func TestTypedCache_TypeMismatch(t *testing.T) {
+	cache := NewTypedCache[string, int](2, 50, nil)
+	cache.c.Set("t1", "1")
+	if _, err := cache.Get("t1"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatal("type mismatch should be returned, got ", err)
+	}
+	cache.c.Set("t2", nil)
+	if _, err := cache.Get("t2"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatal("nil should be a type mismatch for int, got ", err)
+	}
+
+	iface := NewTypedCache[string, error](2, 50, nil)
+	iface.Set("t1", nil)
+	if v, err := iface.Get("t1"); err != nil || v != nil {
+		t.Fatal("nil should be returned for interface value, got ", v, err)
+	}
+}